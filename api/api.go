@@ -0,0 +1,293 @@
+// The api package implements the REST API service. The router it provides
+// can be served on its own, as the main program does, or mounted inside
+// another service.
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+// Options configures the router
+type Options struct {
+	// OpenAPI is the contract incoming requests are validated against,
+	// defaults to the document embedded in the package
+	OpenAPI *OpenAPI
+	// ValidateResponses enables the validation of the responses against
+	// the contract when set, every violation is reported to the function.
+	// It's meant to be used in tests.
+	ValidateResponses func(r *http.Request, err error)
+	// Domain the tenants are served from, e.g. requests sent to
+	// acme.gym.example.com belong to the tenant acme when it's
+	// gym.example.com. Requests to other hosts, or any host when empty, go
+	// to the default tenant unless the principal says otherwise.
+	Domain string
+}
+
+// Server holds the dependencies of the handlers
+type Server struct {
+	storage storage.Storage
+}
+
+// NewServer creates a Server using the given storage
+func NewServer(s storage.Storage) *Server {
+	return &Server{storage: s}
+}
+
+// NewRouter creates the routes of our API on top of the given storage.
+// Incoming requests are validated against the OpenAPI document before
+// reaching the handlers.
+func NewRouter(s storage.Storage, opts Options) http.Handler {
+	doc := opts.OpenAPI
+	if doc == nil {
+		doc = mustLoadOpenAPI()
+	}
+	srv := NewServer(s)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	if opts.ValidateResponses != nil {
+		r.Use(doc.ValidateResponses(opts.ValidateResponses))
+	}
+	r.Use(Recoverer)
+	r.Use(doc.ValidateRequests)
+	r.Use(srv.TenantCtx(opts.Domain))
+
+	// healthcheck for containerized deployments
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	// classes
+	r.Route("/classes", func(r chi.Router) {
+		r.Get("/", srv.ListClasses)
+		r.Post("/", srv.CreateClass)
+		r.Route("/{classID}", func(r chi.Router) {
+			r.Use(srv.ClassCtx)
+			r.Get("/", srv.GetClass)
+			r.Put("/", srv.UpdateClass)
+			r.Delete("/", srv.DeleteClass)
+			r.Post("/sessions/{date}/attendance", srv.RecordAttendance)
+		})
+	})
+
+	// bookings
+	r.Route("/bookings", func(r chi.Router) {
+		r.Get("/", srv.ListBookings)
+		r.Post("/", srv.CreateBooking)
+		r.Route("/{bookingID}", func(r chi.Router) {
+			r.Use(srv.BookingCtx)
+			r.Get("/", srv.GetBooking)
+			r.Put("/", srv.UpdateBooking)
+			r.Delete("/", srv.DeleteBooking)
+			r.Post("/cancel", srv.CancelBooking)
+			r.Post("/checkin", srv.CheckIn)
+		})
+	})
+
+	// instructors
+	r.Route("/instructors", func(r chi.Router) {
+		r.Get("/", srv.ListInstructors)
+		r.Post("/", srv.CreateInstructor)
+		r.Route("/{instructorID}", func(r chi.Router) {
+			r.Use(srv.InstructorCtx)
+			r.Get("/", srv.GetInstructor)
+			r.Put("/", srv.UpdateInstructor)
+			r.Delete("/", srv.DeleteInstructor)
+			r.Get("/schedule", srv.GetSchedule)
+		})
+	})
+
+	// locations and their rooms
+	r.Route("/locations", func(r chi.Router) {
+		r.Get("/", srv.ListLocations)
+		r.Post("/", srv.CreateLocation)
+		r.Route("/{locationID}", func(r chi.Router) {
+			r.Use(srv.LocationCtx)
+			r.Get("/", srv.GetLocation)
+			r.Put("/", srv.UpdateLocation)
+			r.Delete("/", srv.DeleteLocation)
+		})
+	})
+	r.Route("/rooms", func(r chi.Router) {
+		r.Get("/", srv.ListRooms)
+		r.Post("/", srv.CreateRoom)
+		r.Route("/{roomID}", func(r chi.Router) {
+			r.Use(srv.RoomCtx)
+			r.Get("/", srv.GetRoom)
+			r.Put("/", srv.UpdateRoom)
+			r.Delete("/", srv.DeleteRoom)
+		})
+	})
+
+	// blackouts closing the studio
+	r.Route("/blackouts", func(r chi.Router) {
+		r.Get("/", srv.ListBlackouts)
+		r.Post("/", srv.CreateBlackout)
+		r.Route("/{blackoutID}", func(r chi.Router) {
+			r.Use(srv.BlackoutCtx)
+			r.Get("/", srv.GetBlackout)
+			r.Delete("/", srv.DeleteBlackout)
+		})
+	})
+
+	// bans, for the staff
+	r.Route("/bans", func(r chi.Router) {
+		r.Get("/", srv.ListBans)
+		r.Route("/{banID}", func(r chi.Router) {
+			r.Use(srv.BanCtx)
+			r.Get("/", srv.GetBan)
+			r.Post("/lift", srv.LiftBan)
+		})
+	})
+
+	return r
+}
+
+// ClassCtx loads and injects a Class object into the request.
+// In case the Class cannot be found, it returns a 404
+func (s *Server) ClassCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		class, err := s.store(r).GetClass(chi.URLParam(r, "classID"))
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "class", class)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// BookingCtx loads and injects a Class object into the request.
+// In case the Class cannot be found, it returns a 404
+func (s *Server) BookingCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "bookingID"))
+		if err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+
+		booking, err := s.store(r).GetBooking(id)
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "booking", booking)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// BanCtx loads and injects a Ban object into the request.
+// In case the Ban cannot be found, it returns a 404
+func (s *Server) BanCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "banID"))
+		if err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+
+		ban, err := s.store(r).GetBan(id)
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "ban", ban)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// InstructorCtx loads and injects an Instructor object into the request.
+// In case the Instructor cannot be found, it returns a 404
+func (s *Server) InstructorCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "instructorID"))
+		if err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+
+		instructor, err := s.store(r).GetInstructor(id)
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "instructor", instructor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LocationCtx loads and injects a Location object into the request.
+// In case the Location cannot be found, it returns a 404
+func (s *Server) LocationCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "locationID"))
+		if err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+
+		location, err := s.store(r).GetLocation(id)
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "location", location)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RoomCtx loads and injects a Room object into the request.
+// In case the Room cannot be found, it returns a 404
+func (s *Server) RoomCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "roomID"))
+		if err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+
+		room, err := s.store(r).GetRoom(id)
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "room", room)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// BlackoutCtx loads and injects a Blackout object into the request.
+// In case the Blackout cannot be found, it returns a 404
+func (s *Server) BlackoutCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "blackoutID"))
+		if err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+
+		blackout, err := s.store(r).GetBlackout(id)
+		if err != nil {
+			render.Render(w, r, ErrNotFound(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "blackout", blackout)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,949 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+	"github.com/masci/go-rest-playground/storage/storagetest"
+)
+
+/*
+	End-to-end tests
+
+	These tests drive every route through the real router served by an
+	httptest.Server, once for each storage backend. Responses are validated
+	against the OpenAPI document as well.
+*/
+
+// backends create the storages, seeded, along with the options given
+var backends = map[string]func(t *testing.T, opts ...storage.Option) storage.Storage{
+	"volatile": func(t *testing.T, opts ...storage.Option) storage.Storage {
+		return storage.NewVolatileStorage(append(opts, storage.WithSeed(storage.DefaultSeed()))...)
+	},
+	"sqlite": func(t *testing.T, opts ...storage.Option) storage.Storage {
+		return storage.NewSqliteStorage(":memory:", append(opts, storage.WithSeed(storage.DefaultSeed()))...)
+	},
+	"bolt": func(t *testing.T, opts ...storage.Option) storage.Storage {
+		return storage.NewBoltStorage(filepath.Join(t.TempDir(), "gym.db"), append(opts, storage.WithSeed(storage.DefaultSeed()))...)
+	},
+}
+
+// e2e wraps a test server
+type e2e struct {
+	url  string
+	host string // sent in place of the one of the server, when set
+}
+
+func newE2E(t *testing.T, s storage.Storage, opts Options) *e2e {
+	srv := httptest.NewServer(NewRouter(s, opts))
+	t.Cleanup(func() {
+		srv.Close()
+		s.Close()
+	})
+
+	return &e2e{url: srv.URL}
+}
+
+// expect sends the request and checks the status code of the response,
+// returning its body
+func (e *e2e) expect(t *testing.T, method, path, body string, status int) []byte {
+	t.Helper()
+
+	req, err := http.NewRequest(method, e.url+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.host != "" {
+		req.Host = e.host
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != status {
+		t.Errorf("%s %s: status code: got %v want %v, body: %s", method, path, resp.StatusCode, status, data)
+	}
+
+	return data
+}
+
+// decode unmarshals a JSON response body
+func (e *e2e) decode(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("invalid JSON %s: %s", data, err)
+	}
+}
+
+// encode marshals a request body
+func (e *e2e) encode(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestEndToEnd(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			t.Run("ping", func(t *testing.T) {
+				if body := e.expect(t, "GET", "/ping", "", http.StatusOK); string(body) != "pong" {
+					t.Errorf("got %s, want %s", body, "pong")
+				}
+			})
+
+			t.Run("classes", func(t *testing.T) {
+				classes := []*models.Class{}
+				e.decode(t, e.expect(t, "GET", "/classes", "", http.StatusOK), &classes)
+				if len(classes) != 4 {
+					t.Errorf("got %d, want %d", len(classes), 4)
+				}
+
+				// create
+				class := &models.Class{}
+				e.decode(t, e.expect(t, "POST", "/classes", `{"name":"Crossfit","start_date":"2022-01-29T00:00:00Z","end_date":"2022-02-28T00:00:00Z","capacity":100}`, http.StatusCreated), class)
+				if class.ID == "" || class.Name != "Crossfit" {
+					t.Errorf("unexpected class: %+v", class)
+				}
+				e.expect(t, "POST", "/classes", `{"name":"Crossfit"}`, http.StatusBadRequest)
+				e.decode(t, e.expect(t, "GET", "/classes/", "", http.StatusOK), &classes)
+				if len(classes) != 5 {
+					t.Errorf("got %d, want %d", len(classes), 5)
+				}
+
+				// read and update
+				e.decode(t, e.expect(t, "GET", "/classes/"+class.ID, "", http.StatusOK), class)
+				e.decode(t, e.expect(t, "PUT", "/classes/"+class.ID, `{"name":"Crossfit","start_date":"2022-01-29T00:00:00Z","end_date":"2022-02-28T00:00:00Z","capacity":50}`, http.StatusOK), class)
+				if class.Capacity != 50 {
+					t.Errorf("got %d, want %d", class.Capacity, 50)
+				}
+				e.decode(t, e.expect(t, "GET", "/classes/"+class.ID, "", http.StatusOK), class)
+				if class.Capacity != 50 {
+					t.Errorf("got %d, want %d", class.Capacity, 50)
+				}
+				e.expect(t, "PUT", "/classes/"+class.ID, `{"name":1}`, http.StatusBadRequest)
+
+				// delete
+				e.expect(t, "DELETE", "/classes/"+class.ID, "", http.StatusOK)
+				e.expect(t, "GET", "/classes/"+class.ID, "", http.StatusNotFound)
+				e.expect(t, "PUT", "/classes/XX0000", `{"name":"Crossfit","start_date":"2022-01-29T00:00:00Z","end_date":"2022-02-28T00:00:00Z"}`, http.StatusNotFound)
+				e.expect(t, "DELETE", "/classes/XX0000", "", http.StatusNotFound)
+			})
+
+			t.Run("bookings", func(t *testing.T) {
+				bookings := []*models.Booking{}
+				e.decode(t, e.expect(t, "GET", "/bookings", "", http.StatusOK), &bookings)
+				if len(bookings) != 0 {
+					t.Errorf("got %d, want %d", len(bookings), 0)
+				}
+
+				// create
+				e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2022-01-30T00:00:00Z","class":"FB0001"}`, http.StatusBadRequest)
+				e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"XX0000"}`, http.StatusBadRequest)
+				booking := &models.Booking{}
+				e.decode(t, e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated), booking)
+				if booking.ID != 1 {
+					t.Errorf("got %d, want %d", booking.ID, 1)
+				}
+				e.decode(t, e.expect(t, "GET", "/bookings", "", http.StatusOK), &bookings)
+				if len(bookings) != 1 {
+					t.Errorf("got %d, want %d", len(bookings), 1)
+				}
+
+				// read and update
+				e.decode(t, e.expect(t, "GET", "/bookings/1", "", http.StatusOK), booking)
+				if booking.Customer != "Jane Doe" {
+					t.Errorf("got %s, want %s", booking.Customer, "Jane Doe")
+				}
+				e.decode(t, e.expect(t, "PUT", "/bookings/1", `{"customer":"John Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusOK), booking)
+				e.decode(t, e.expect(t, "GET", "/bookings/1", "", http.StatusOK), booking)
+				if booking.Customer != "John Doe" {
+					t.Errorf("got %s, want %s", booking.Customer, "John Doe")
+				}
+				// the class isn't available on that day
+				e.expect(t, "PUT", "/bookings/1", `{"customer":"John Doe","date":"2020-03-30T00:00:00Z","class":"FB0001"}`, http.StatusBadRequest)
+				e.expect(t, "GET", "/bookings/abc", "", http.StatusBadRequest)
+				e.expect(t, "GET", "/bookings/42", "", http.StatusNotFound)
+
+				// delete
+				e.decode(t, e.expect(t, "DELETE", "/bookings/1", "", http.StatusOK), booking)
+				if booking.ID != 1 {
+					t.Errorf("got %d, want %d", booking.ID, 1)
+				}
+				e.expect(t, "GET", "/bookings/1", "", http.StatusNotFound)
+				e.expect(t, "DELETE", "/bookings/1", "", http.StatusNotFound)
+			})
+
+			t.Run("unknown routes", func(t *testing.T) {
+				e.expect(t, "GET", "/unknown", "", http.StatusNotFound)
+				e.expect(t, "PATCH", "/classes/PI0001", "{}", http.StatusMethodNotAllowed)
+			})
+		})
+	}
+}
+
+func TestDeleteClassCancelsBookings(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+			e.expect(t, "POST", "/bookings", `{"customer":"John Doe","date":"2020-01-30T00:00:00Z","class":"PI0001"}`, http.StatusCreated)
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-02T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+			e.expect(t, "PUT", "/classes/FB0001", `{"name":"Full Body","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20,"cancellation_policy":{"cutoff":"24h","fee":1000}}`, http.StatusOK)
+
+			// the bookings of the class are cancelled along with it, for free
+			e.expect(t, "DELETE", "/classes/FB0001", "", http.StatusOK)
+			bookings := []*models.Booking{}
+			e.decode(t, e.expect(t, "GET", "/bookings?status=confirmed", "", http.StatusOK), &bookings)
+			if len(bookings) != 1 || bookings[0].Class != "PI0001" {
+				t.Errorf("got %+v, want the booking of PI0001 only", bookings)
+			}
+			e.decode(t, e.expect(t, "GET", "/bookings?status=cancelled", "", http.StatusOK), &bookings)
+			if len(bookings) != 2 || bookings[0].CancelReason != "class deleted" {
+				t.Errorf("got %+v, want the bookings of FB0001", bookings)
+			}
+			for _, b := range bookings {
+				if b.Cancellation != models.CancelFree || b.CancelFee != 0 {
+					t.Errorf("got %s cancellation with fee %d, want %s", b.Cancellation, b.CancelFee, models.CancelFree)
+				}
+			}
+		})
+	}
+}
+
+// failingDeleteStorage fails to delete classes within transactions with
+// the error given
+type failingDeleteStorage struct {
+	storage.Storage
+	err error
+}
+
+type failingDeleteTx struct {
+	storage.Tx
+	err error
+}
+
+func (tx *failingDeleteTx) DeleteClass(ID string) error {
+	return tx.err
+}
+
+func (s *failingDeleteStorage) WithTx(ctx context.Context, fn func(tx storage.Tx) error) error {
+	return s.Storage.WithTx(ctx, func(tx storage.Tx) error {
+		return fn(&failingDeleteTx{tx, s.err})
+	})
+}
+
+func (s *failingDeleteStorage) ForTenant(ID string) storage.Storage {
+	return &failingDeleteStorage{s.Storage.ForTenant(ID), s.err}
+}
+
+func TestDeleteClassErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("class FB0001: %w", storage.ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("class FB0001: %w", storage.ErrConflict), http.StatusConflict},
+		{errors.New("disk I/O error"), http.StatusInternalServerError},
+	}
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			logs := captureLog(t)
+			for _, tt := range tests {
+				e := newE2E(t, &failingDeleteStorage{newStorage(t), tt.err}, Options{
+					ValidateResponses: func(r *http.Request, err error) {
+						t.Errorf("invalid response: %s", err)
+					},
+				})
+				e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+
+				e.expect(t, "DELETE", "/classes/FB0001", "", tt.status)
+
+				// nothing changed, the booking is still confirmed
+				e.expect(t, "GET", "/classes/FB0001", "", http.StatusOK)
+				bookings := []*models.Booking{}
+				e.decode(t, e.expect(t, "GET", "/bookings?status=confirmed", "", http.StatusOK), &bookings)
+				if len(bookings) != 1 {
+					t.Errorf("got %d confirmed bookings, want %d", len(bookings), 1)
+				}
+			}
+
+			// the unexpected failure is logged
+			if !strings.Contains(logs.String(), "deleting class FB0001: disk I/O error") {
+				t.Errorf("error not found in logs: %s", logs.String())
+			}
+		})
+	}
+}
+
+func TestCancelBooking(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			// fill up the class, cancelling a day before costs 10.00
+			e.expect(t, "PUT", "/classes/FB0001", `{"name":"Full Body","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":1,"cancellation_policy":{"cutoff":"-24h"}}`, http.StatusBadRequest)
+			e.expect(t, "PUT", "/classes/FB0001", `{"name":"Full Body","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":1,"cancellation_policy":{"cutoff":"a day"}}`, http.StatusBadRequest)
+			e.expect(t, "PUT", "/classes/FB0001", `{"name":"Full Body","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":1,"cancellation_policy":{"cutoff":"24h","fee":1000}}`, http.StatusOK)
+			booking := &models.Booking{}
+			e.decode(t, e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated), booking)
+			if booking.Status != models.BookingConfirmed {
+				t.Errorf("got %s, want %s", booking.Status, models.BookingConfirmed)
+			}
+			e.expect(t, "POST", "/bookings", `{"customer":"John Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusConflict)
+
+			// cancel
+			e.expect(t, "POST", "/bookings/1/cancel", `{"reason":"sick"}`, http.StatusBadRequest)
+			e.expect(t, "POST", "/bookings/42/cancel", `{"by":"Jane Doe"}`, http.StatusNotFound)
+			e.decode(t, e.expect(t, "POST", "/bookings/1/cancel", `{"by":"Jane Doe","reason":"sick"}`, http.StatusOK), booking)
+			if booking.Status != models.BookingCancelled || booking.CancelledBy != "Jane Doe" || booking.CancelReason != "sick" || booking.CancelledAt == nil {
+				t.Errorf("unexpected booking: %+v", booking)
+			}
+			// the class took place long ago
+			if booking.Cancellation != models.CancelLate || booking.CancelFee != 1000 {
+				t.Errorf("got %s cancellation with fee %d, want %s with fee %d", booking.Cancellation, booking.CancelFee, models.CancelLate, 1000)
+			}
+			e.expect(t, "POST", "/bookings/1/cancel", `{"by":"Jane Doe"}`, http.StatusConflict)
+
+			// cancelled bookings can't be moved
+			e.expect(t, "PUT", "/bookings/1", `{"customer":"Jane Doe","date":"2020-01-31T00:00:00Z","class":"FB0001"}`, http.StatusConflict)
+
+			// the status can't be changed by updating the booking
+			e.decode(t, e.expect(t, "PUT", "/bookings/1", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001","status":"confirmed"}`, http.StatusOK), booking)
+			if booking.Status != models.BookingCancelled {
+				t.Errorf("got %s, want %s", booking.Status, models.BookingCancelled)
+			}
+
+			// the spot is free again, and the cancelled booking is still there
+			e.expect(t, "POST", "/bookings", `{"customer":"John Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+			e.decode(t, e.expect(t, "GET", "/bookings/1", "", http.StatusOK), booking)
+			if booking.Status != models.BookingCancelled {
+				t.Errorf("got %s, want %s", booking.Status, models.BookingCancelled)
+			}
+			e.expect(t, "GET", "/bookings?status=unknown", "", http.StatusBadRequest)
+		})
+	}
+}
+
+func TestCheckIn(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			// the check-in window of a past session is closed
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+			e.expect(t, "POST", "/bookings/1/checkin", "", http.StatusConflict)
+			e.expect(t, "POST", "/bookings/42/checkin", "", http.StatusNotFound)
+
+			// book a session taking place right now
+			now := time.Now().UTC().Truncate(time.Second)
+			class := &models.Class{Name: "Spinning", StartDate: now.Add(-24 * time.Hour), EndDate: now.Add(24 * time.Hour)}
+			e.decode(t, e.expect(t, "POST", "/classes", e.encode(t, class), http.StatusCreated), class)
+			for _, customer := range []string{"Jane Doe", "John Doe", "Foo Bar"} {
+				e.expect(t, "POST", "/bookings", e.encode(t, &models.Booking{Customer: customer, Class: class.ID, Date: now}), http.StatusCreated)
+			}
+
+			booking := &models.Booking{}
+			e.decode(t, e.expect(t, "POST", "/bookings/2/checkin", "", http.StatusOK), booking)
+			if booking.Status != models.BookingAttended || booking.CheckedInAt == nil {
+				t.Errorf("unexpected booking: %+v", booking)
+			}
+			e.expect(t, "POST", "/bookings/2/checkin", "", http.StatusConflict)
+
+			// the attendance of a session is recorded at once, or not at all
+			session := "/classes/" + class.ID + "/sessions/" + now.Format("2006-01-02") + "/attendance"
+			e.expect(t, "POST", session, `{"attended":[3,1]}`, http.StatusBadRequest)
+			e.expect(t, "POST", session, `{"attended":[3,2]}`, http.StatusConflict)
+			e.expect(t, "POST", "/classes/XX0000/sessions/2020-01-30/attendance", `{"attended":[]}`, http.StatusNotFound)
+			bookings := []*models.Booking{}
+			e.decode(t, e.expect(t, "POST", session, `{"attended":[3]}`, http.StatusOK), &bookings)
+			if len(bookings) != 3 {
+				t.Fatalf("got %d bookings, want %d", len(bookings), 3)
+			}
+			for i, want := range []string{models.BookingAttended, models.BookingAttended, models.BookingConfirmed} {
+				if bookings[i].Status != want {
+					t.Errorf("booking %d: got %s, want %s", bookings[i].ID, bookings[i].Status, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBans(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage(t)
+			expiry := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+			if _, err := s.AddBan(&models.Ban{Customer: "Jane Doe", Reason: "4 no-shows", CreatedAt: time.Now(), ExpiresAt: expiry}); err != nil {
+				t.Fatal(err)
+			}
+			e := newE2E(t, s, Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			// banned customers are told until when
+			body := map[string]string{}
+			e.decode(t, e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusForbidden), &body)
+			if body["banned_until"] != expiry.Format(time.RFC3339) {
+				t.Errorf("got %s, want %s", body["banned_until"], expiry.Format(time.RFC3339))
+			}
+			e.expect(t, "POST", "/bookings", `{"customer":"John Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+
+			// the staff can list and lift bans
+			bans := []*models.Ban{}
+			e.decode(t, e.expect(t, "GET", "/bans?active=true", "", http.StatusOK), &bans)
+			if len(bans) != 1 || bans[0].Customer != "Jane Doe" {
+				t.Errorf("got %+v, want the ban of Jane Doe", bans)
+			}
+			e.expect(t, "GET", "/bans/42", "", http.StatusNotFound)
+			e.expect(t, "POST", "/bans/1/lift", `{}`, http.StatusBadRequest)
+			ban := &models.Ban{}
+			e.decode(t, e.expect(t, "POST", "/bans/1/lift", `{"by":"front desk"}`, http.StatusOK), ban)
+			if ban.LiftedBy != "front desk" || ban.LiftedAt == nil {
+				t.Errorf("unexpected ban: %+v", ban)
+			}
+			e.expect(t, "POST", "/bans/1/lift", `{"by":"front desk"}`, http.StatusConflict)
+			e.decode(t, e.expect(t, "GET", "/bans?active=false", "", http.StatusOK), &bans)
+			if len(bans) != 1 {
+				t.Errorf("got %d, want %d", len(bans), 1)
+			}
+
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+		})
+	}
+}
+
+func TestBansClock(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			// bans are in force according to the clock of the storage
+			clock := storagetest.NewFakeClock(time.Date(2020, 1, 29, 9, 0, 0, 0, time.UTC))
+			s := newStorage(t, storage.WithClock(clock))
+			ban := &models.Ban{Customer: "Jane Doe", CreatedAt: clock.Now(), ExpiresAt: clock.Now().Add(time.Hour)}
+			if _, err := s.AddBan(ban); err != nil {
+				t.Fatal(err)
+			}
+			e := newE2E(t, s, Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			bans := []*models.Ban{}
+			e.decode(t, e.expect(t, "GET", "/bans?active=true", "", http.StatusOK), &bans)
+			if len(bans) != 1 {
+				t.Errorf("got %d active bans, want %d", len(bans), 1)
+			}
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusForbidden)
+
+			clock.Set(ban.ExpiresAt)
+			e.decode(t, e.expect(t, "GET", "/bans?active=true", "", http.StatusOK), &bans)
+			if len(bans) != 0 {
+				t.Errorf("got %d active bans, want %d", len(bans), 0)
+			}
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+		})
+	}
+}
+
+func TestInstructors(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			e.expect(t, "POST", "/instructors", `{"email":"jane@example.com"}`, http.StatusBadRequest)
+			instructor := &models.Instructor{}
+			e.decode(t, e.expect(t, "POST", "/instructors", `{"name":"Jane","email":"jane@example.com"}`, http.StatusCreated), instructor)
+			e.expect(t, "GET", "/instructors/42", "", http.StatusNotFound)
+			e.expect(t, "PUT", fmt.Sprintf("/instructors/%d", instructor.ID), `{"name":"Jane Doe"}`, http.StatusOK)
+			instructors := []*models.Instructor{}
+			e.decode(t, e.expect(t, "GET", "/instructors", "", http.StatusOK), &instructors)
+			if len(instructors) != 1 || instructors[0].Name != "Jane Doe" {
+				t.Errorf("got %+v, want Jane Doe", instructors)
+			}
+
+			// the instructor teaches a class running from yesterday to next month
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			class := &models.Class{Name: "Pilates", StartDate: today.AddDate(0, 0, -1), EndDate: today.AddDate(0, 1, 0), Instructor: instructor.ID}
+			e.decode(t, e.expect(t, "POST", "/classes", e.encode(t, class), http.StatusCreated), class)
+			overlapping := &models.Class{Name: "Yoga", StartDate: today.AddDate(0, 1, 0), EndDate: today.AddDate(0, 2, 0), Instructor: instructor.ID}
+			e.expect(t, "POST", "/classes", e.encode(t, overlapping), http.StatusConflict)
+			overlapping.Instructor = 42
+			e.expect(t, "POST", "/classes", e.encode(t, overlapping), http.StatusBadRequest)
+			tomorrow := &models.Booking{Customer: "John Doe", Class: class.ID, Date: today.AddDate(0, 0, 1)}
+			e.expect(t, "POST", "/bookings", e.encode(t, tomorrow), http.StatusCreated)
+
+			// the schedule starts today
+			sessions := []*models.Session{}
+			e.decode(t, e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule?days=3", instructor.ID), "", http.StatusOK), &sessions)
+			if len(sessions) != 3 {
+				t.Fatalf("got %d sessions, want %d", len(sessions), 3)
+			}
+			if sessions[0].Date != today.Format("2006-01-02") || sessions[0].Class != class.ID || sessions[0].Booked != 0 {
+				t.Errorf("unexpected session: %+v", sessions[0])
+			}
+			if sessions[1].Booked != 1 {
+				t.Errorf("got %d bookings, want %d", sessions[1].Booked, 1)
+			}
+			e.decode(t, e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule", instructor.ID), "", http.StatusOK), &sessions)
+			if len(sessions) != 7 {
+				t.Errorf("got %d sessions, want %d", len(sessions), 7)
+			}
+			e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule?days=0", instructor.ID), "", http.StatusBadRequest)
+			e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule?days=1000", instructor.ID), "", http.StatusBadRequest)
+
+			// a morning and an evening class on the same days don't clash
+			start, end := today.AddDate(0, 2, 0), today.AddDate(0, 3, 0)
+			morning := &models.Class{Name: "Yoga", StartDate: start, EndDate: end, StartTime: "09:00", Duration: models.Duration(time.Hour), Instructor: instructor.ID}
+			e.decode(t, e.expect(t, "POST", "/classes", e.encode(t, morning), http.StatusCreated), morning)
+			evening := fmt.Sprintf(`{"name":"Boxing","start_date":"%s","end_date":"%s","start_time":"18:00","duration":"1h","instructor":%d}`,
+				start.Format(time.RFC3339), end.Format(time.RFC3339), instructor.ID)
+			e.expect(t, "POST", "/classes", evening, http.StatusCreated)
+			e.expect(t, "POST", "/classes", strings.Replace(evening, "18:00", "09:30", 1), http.StatusConflict)
+			e.expect(t, "POST", "/classes", strings.Replace(evening, "18:00", "9am", 1), http.StatusBadRequest)
+
+			// instructors can't be deleted while they teach
+			e.expect(t, "DELETE", fmt.Sprintf("/instructors/%d", instructor.ID), "", http.StatusConflict)
+			classes := []*models.Class{}
+			e.decode(t, e.expect(t, "GET", "/classes/", "", http.StatusOK), &classes)
+			for _, c := range classes {
+				if c.Instructor == instructor.ID && c.ID != class.ID {
+					e.expect(t, "DELETE", "/classes/"+c.ID, "", http.StatusOK)
+				}
+			}
+			e.expect(t, "DELETE", "/classes/"+class.ID, "", http.StatusOK)
+			e.expect(t, "DELETE", fmt.Sprintf("/instructors/%d", instructor.ID), "", http.StatusOK)
+		})
+	}
+}
+
+func TestScheduleClock(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			// the schedule starts today according to the clock of the storage
+			clock := storagetest.NewFakeClock(time.Date(2020, 2, 10, 9, 0, 0, 0, time.UTC))
+			e := newE2E(t, newStorage(t, storage.WithClock(clock)), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			instructor := &models.Instructor{}
+			e.decode(t, e.expect(t, "POST", "/instructors", `{"name":"Jane"}`, http.StatusCreated), instructor)
+			class := &models.Class{Name: "Boxing", StartDate: time.Date(2020, 2, 11, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC), Instructor: instructor.ID}
+			e.decode(t, e.expect(t, "POST", "/classes", e.encode(t, class), http.StatusCreated), class)
+
+			sessions := []*models.Session{}
+			e.decode(t, e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule?days=3", instructor.ID), "", http.StatusOK), &sessions)
+			if len(sessions) != 2 || sessions[0].Date != "2020-02-11" || sessions[1].Date != "2020-02-12" {
+				t.Errorf("got %+v, want the sessions of 2020-02-11 and 2020-02-12", sessions)
+			}
+		})
+	}
+}
+
+// countingStorage counts the reads the schedule of an instructor depends on
+type countingStorage struct {
+	storage.Storage
+	reads *int32
+}
+
+func (s *countingStorage) GetBlackouts() ([]*models.Blackout, error) {
+	atomic.AddInt32(s.reads, 1)
+	return s.Storage.GetBlackouts()
+}
+
+func (s *countingStorage) GetRoom(ID int) (*models.Room, error) {
+	atomic.AddInt32(s.reads, 1)
+	return s.Storage.GetRoom(ID)
+}
+
+func (s *countingStorage) GetLocation(ID int) (*models.Location, error) {
+	atomic.AddInt32(s.reads, 1)
+	return s.Storage.GetLocation(ID)
+}
+
+func (s *countingStorage) ForTenant(ID string) storage.Storage {
+	return &countingStorage{s.Storage.ForTenant(ID), s.reads}
+}
+
+func TestScheduleReads(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			reads := int32(0)
+			e := newE2E(t, &countingStorage{newStorage(t), &reads}, Options{})
+
+			location, room, instructor := &models.Location{}, &models.Room{}, &models.Instructor{}
+			e.decode(t, e.expect(t, "POST", "/locations", `{"name":"Downtown","time_zone":"Europe/Rome"}`, http.StatusCreated), location)
+			e.decode(t, e.expect(t, "POST", "/rooms", fmt.Sprintf(`{"location":%d,"name":"Studio 1"}`, location.ID), http.StatusCreated), room)
+			e.decode(t, e.expect(t, "POST", "/instructors", `{"name":"Jane"}`, http.StatusCreated), instructor)
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			class := &models.Class{Name: "Boxing", StartDate: today, EndDate: today.AddDate(1, 0, 0), Instructor: instructor.ID, Room: room.ID}
+			e.expect(t, "POST", "/classes", e.encode(t, class), http.StatusCreated)
+			blackout := &models.Blackout{StartDate: today.AddDate(0, 0, 3), EndDate: today.AddDate(0, 0, 3), Location: location.ID}
+			e.expect(t, "POST", "/blackouts", e.encode(t, blackout), http.StatusCreated)
+
+			// the storage is read as much for a day as for a year
+			counts := map[int]int32{}
+			for _, days := range []int{1, 366} {
+				atomic.StoreInt32(&reads, 0)
+				e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule?days=%d", instructor.ID, days), "", http.StatusOK)
+				counts[days] = atomic.LoadInt32(&reads)
+			}
+			if counts[366] != counts[1] {
+				t.Errorf("got %d reads for a year, want %d as for a day", counts[366], counts[1])
+			}
+		})
+	}
+}
+
+func TestLocationsAndRooms(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			downtown, uptown := &models.Location{}, &models.Location{}
+			e.decode(t, e.expect(t, "POST", "/locations", `{"name":"Downtown","address":"1 Main St"}`, http.StatusCreated), downtown)
+			e.decode(t, e.expect(t, "POST", "/locations", `{"name":"Uptown"}`, http.StatusCreated), uptown)
+			e.expect(t, "GET", "/locations/42", "", http.StatusNotFound)
+			e.expect(t, "POST", "/rooms", `{"location":42,"name":"Studio 1"}`, http.StatusBadRequest)
+			small, big := &models.Room{}, &models.Room{}
+			e.decode(t, e.expect(t, "POST", "/rooms", fmt.Sprintf(`{"location":%d,"name":"Studio 1","capacity":1}`, downtown.ID), http.StatusCreated), small)
+			e.decode(t, e.expect(t, "POST", "/rooms", fmt.Sprintf(`{"location":%d,"name":"Studio 2"}`, uptown.ID), http.StatusCreated), big)
+			rooms := []*models.Room{}
+			e.decode(t, e.expect(t, "GET", fmt.Sprintf("/rooms?location=%d", uptown.ID), "", http.StatusOK), &rooms)
+			if len(rooms) != 1 || rooms[0].ID != big.ID {
+				t.Errorf("got %+v, want %+v", rooms, big)
+			}
+
+			// classes can't share a room at the same time
+			class := &models.Class{}
+			e.decode(t, e.expect(t, "POST", "/classes", fmt.Sprintf(`{"name":"Pilates","start_date":"2020-01-01T00:00:00Z","end_date":"2020-01-31T00:00:00Z","capacity":10,"room":%d}`, small.ID), http.StatusCreated), class)
+			e.expect(t, "POST", "/classes", fmt.Sprintf(`{"name":"Yoga","start_date":"2020-01-15T00:00:00Z","end_date":"2020-02-15T00:00:00Z","room":%d}`, small.ID), http.StatusConflict)
+			e.expect(t, "POST", "/classes", fmt.Sprintf(`{"name":"Yoga","start_date":"2020-01-15T00:00:00Z","end_date":"2020-02-15T00:00:00Z","room":%d}`, big.ID), http.StatusCreated)
+			classes := []*models.Class{}
+			e.decode(t, e.expect(t, "GET", fmt.Sprintf("/classes?location=%d", downtown.ID), "", http.StatusOK), &classes)
+			if len(classes) != 1 || classes[0].ID != class.ID {
+				t.Errorf("got %+v, want %+v", classes, class)
+			}
+
+			// timed sessions can follow each other back to back
+			session := `{"name":"%s","start_date":"2020-03-01T00:00:00Z","end_date":"2020-03-31T00:00:00Z","start_time":"%s","duration":"1h","room":%d}`
+			e.expect(t, "POST", "/classes", fmt.Sprintf(session, "Boxing", "09:00", big.ID), http.StatusCreated)
+			e.expect(t, "POST", "/classes", fmt.Sprintf(session, "Spinning", "10:00", big.ID), http.StatusCreated)
+			e.expect(t, "POST", "/classes", fmt.Sprintf(session, "Zumba", "09:30", big.ID), http.StatusConflict)
+
+			// the room caps the capacity of the class
+			e.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"Jane Doe","date":"2020-01-15T00:00:00Z","class":"%s"}`, class.ID), http.StatusCreated)
+			e.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"John Doe","date":"2020-01-15T00:00:00Z","class":"%s"}`, class.ID), http.StatusConflict)
+
+			e.expect(t, "DELETE", fmt.Sprintf("/rooms/%d", small.ID), "", http.StatusConflict)
+			e.expect(t, "DELETE", fmt.Sprintf("/locations/%d", downtown.ID), "", http.StatusConflict)
+		})
+	}
+}
+
+func TestBookingDays(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			// FB0001 runs from 2020-01-29 to 2020-02-28, both days included
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-29T00:00:00Z","class":"FB0001"}`, http.StatusCreated)
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-28T18:00:00Z","class":"FB0001"}`, http.StatusCreated)
+
+			body := map[string]string{}
+			e.decode(t, e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-29T00:00:00Z","class":"FB0001"}`, http.StatusBadRequest), &body)
+			want := "class Full Body is available from 2020-01-29 to 2020-02-28 (UTC), not on 2020-02-29"
+			if body["error"] != want {
+				t.Errorf("got %q, want %q", body["error"], want)
+			}
+		})
+	}
+}
+
+func TestBookingWindow(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			e.expect(t, "POST", "/classes", `{"name":"Boxing","booking_window":{"opens_days_before":-1}}`, http.StatusBadRequest)
+			e.expect(t, "POST", "/classes", `{"name":"Boxing","booking_window":{"opens_days_before":1,"closes_minutes_before":1440}}`, http.StatusBadRequest)
+
+			// the class runs from yesterday to next month, and can be booked from a week to an hour before
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			class := &models.Class{Name: "Boxing", StartDate: today.AddDate(0, 0, -1), EndDate: today.AddDate(0, 1, 0)}
+			class.BookingWindow = models.BookingWindow{OpensDays: 7, ClosesMinutes: 60}
+			e.decode(t, e.expect(t, "POST", "/classes", e.encode(t, class), http.StatusCreated), class)
+			body := map[string]interface{}{}
+			e.decode(t, e.expect(t, "GET", "/classes/"+class.ID, "", http.StatusOK), &body)
+			if window, _ := body["booking_window"].(map[string]interface{}); window["opens_days_before"] != 7.0 || window["closes_minutes_before"] != 60.0 {
+				t.Errorf("got %v, want the booking window", body["booking_window"])
+			}
+
+			now := time.Now().UTC()
+			soon := &models.Booking{Customer: "Jane Doe", Class: class.ID, Date: now.Add(30 * time.Minute)}
+			e.expect(t, "POST", "/bookings", e.encode(t, soon), http.StatusConflict)
+			later := &models.Booking{Customer: "Jane Doe", Class: class.ID, Date: now.AddDate(0, 0, 8)}
+			e.expect(t, "POST", "/bookings", e.encode(t, later), http.StatusConflict)
+			tomorrow := &models.Booking{Customer: "Jane Doe", Class: class.ID, Date: now.AddDate(0, 0, 1)}
+			e.expect(t, "POST", "/bookings", e.encode(t, tomorrow), http.StatusCreated)
+		})
+	}
+}
+
+func TestTimeZones(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			e.expect(t, "POST", "/locations", `{"name":"Downtown","time_zone":"Mars/Olympus"}`, http.StatusBadRequest)
+			location, room := &models.Location{}, &models.Room{}
+			e.decode(t, e.expect(t, "POST", "/locations", `{"name":"Downtown","time_zone":"Europe/Rome"}`, http.StatusCreated), location)
+			e.decode(t, e.expect(t, "POST", "/rooms", fmt.Sprintf(`{"location":%d,"name":"Studio 1"}`, location.ID), http.StatusCreated), room)
+			class := &models.Class{}
+			e.decode(t, e.expect(t, "POST", "/classes", fmt.Sprintf(`{"name":"Pilates","start_date":"2020-03-29T00:00:00Z","end_date":"2020-10-25T00:00:00Z","capacity":10,"room":%d}`, room.ID), http.StatusCreated), class)
+
+			// 7am in Rome is 6am UTC in winter and 5am UTC in summer
+			winter, summer := &models.Booking{}, &models.Booking{}
+			e.decode(t, e.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"Jane Doe","date":"2020-03-29T06:00:00+01:00","class":"%s"}`, class.ID), http.StatusCreated), winter)
+			e.decode(t, e.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"Jane Doe","date":"2020-03-30T05:00:00Z","class":"%s"}`, class.ID), http.StatusCreated), summer)
+
+			// times are rendered with the offset in force in Rome
+			bookings := []map[string]interface{}{}
+			e.decode(t, e.expect(t, "GET", "/bookings", "", http.StatusOK), &bookings)
+			dates := []interface{}{}
+			for _, b := range bookings {
+				dates = append(dates, b["date"])
+			}
+			if len(dates) != 2 || dates[0] != "2020-03-29T07:00:00+02:00" || dates[1] != "2020-03-30T07:00:00+02:00" {
+				t.Errorf("got %v, want 7am in Rome", dates)
+			}
+
+			// the class starts at midnight in Rome, and its sessions are days in Rome
+			e.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"John Doe","date":"2020-03-28T22:30:00Z","class":"%s"}`, class.ID), http.StatusBadRequest)
+			late := &models.Booking{}
+			e.decode(t, e.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"John Doe","date":"2020-03-29T22:30:00Z","class":"%s"}`, class.ID), http.StatusCreated), late)
+			e.expect(t, "POST", fmt.Sprintf("/classes/%s/sessions/2020-03-29/attendance", class.ID), fmt.Sprintf(`{"attended":[%d]}`, late.ID), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestBlackouts(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			e := newE2E(t, newStorage(t), Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-10T09:00:00Z","class":"FB0001"}`, http.StatusCreated)
+			e.expect(t, "POST", "/bookings", `{"customer":"John Doe","date":"2020-02-11T09:00:00Z","class":"YO0001"}`, http.StatusCreated)
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-12T09:00:00Z","class":"YO0001"}`, http.StatusCreated)
+
+			e.expect(t, "POST", "/blackouts", `{"start_date":"2020-02-12T00:00:00Z","end_date":"2020-02-10T00:00:00Z"}`, http.StatusBadRequest)
+			e.expect(t, "POST", "/blackouts", `{"start_date":"2020-02-10T00:00:00Z","end_date":"2020-02-11T00:00:00Z","class":"XX0001"}`, http.StatusBadRequest)
+
+			// the global blackout cancels the bookings of every class on those days
+			created := &BlackoutPayload{}
+			e.decode(t, e.expect(t, "POST", "/blackouts", `{"start_date":"2020-02-10T00:00:00Z","end_date":"2020-02-11T00:00:00Z","reason":"holidays"}`, http.StatusCreated), created)
+			if len(created.Cancelled) != 2 || created.Cancelled[0].CancelReason != "holidays" || created.Cancelled[0].Cancellation != models.CancelFree {
+				t.Errorf("got %+v, want 2 bookings cancelled for free", created.Cancelled)
+			}
+			if len(created.Customers) != 2 || created.Customers[0] != "Jane Doe" || created.Customers[1] != "John Doe" {
+				t.Errorf("got %v, want Jane Doe and John Doe", created.Customers)
+			}
+			e.expect(t, "GET", fmt.Sprintf("/blackouts/%d", created.ID), "", http.StatusOK)
+			e.expect(t, "GET", "/blackouts/42", "", http.StatusNotFound)
+
+			body := map[string]string{}
+			e.decode(t, e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-11T18:00:00Z","class":"PI0001"}`, http.StatusConflict), &body)
+			if want := "class Pilates is closed from 2020-02-10 to 2020-02-11 (holidays)"; !strings.HasPrefix(body["error"], want) {
+				t.Errorf("got %q, want %q", body["error"], want)
+			}
+
+			// deleting the blackout reopens the days, cancelled bookings stay so
+			e.expect(t, "DELETE", fmt.Sprintf("/blackouts/%d", created.ID), "", http.StatusOK)
+			e.expect(t, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-02-11T18:00:00Z","class":"PI0001"}`, http.StatusCreated)
+			bookings := []*models.Booking{}
+			e.decode(t, e.expect(t, "GET", "/bookings?status=cancelled", "", http.StatusOK), &bookings)
+			if len(bookings) != 2 {
+				t.Errorf("got %d cancelled bookings, want %d", len(bookings), 2)
+			}
+
+			// closed days are left out of the schedule
+			instructor := &models.Instructor{}
+			e.decode(t, e.expect(t, "POST", "/instructors", `{"name":"Jane","email":"jane@example.com"}`, http.StatusCreated), instructor)
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			class := &models.Class{Name: "Spinning", StartDate: today.AddDate(0, 0, -1), EndDate: today.AddDate(0, 1, 0), Instructor: instructor.ID}
+			e.decode(t, e.expect(t, "POST", "/classes", e.encode(t, class), http.StatusCreated), class)
+			tomorrow := &models.Blackout{StartDate: today.AddDate(0, 0, 1), EndDate: today.AddDate(0, 0, 1), Class: class.ID}
+			e.expect(t, "POST", "/blackouts", e.encode(t, tomorrow), http.StatusCreated)
+			sessions := []*models.Session{}
+			e.decode(t, e.expect(t, "GET", fmt.Sprintf("/instructors/%d/schedule?days=3", instructor.ID), "", http.StatusOK), &sessions)
+			if len(sessions) != 2 || sessions[1].Date != today.AddDate(0, 0, 2).Format("2006-01-02") {
+				t.Errorf("got %+v, want today and the day after tomorrow", sessions)
+			}
+		})
+	}
+}
+
+func TestTenants(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage(t)
+			for _, tenant := range []string{"acme", "other"} {
+				if err := s.AddTenant(tenant); err != nil {
+					t.Fatal(err)
+				}
+			}
+			e := newE2E(t, s, Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+				Domain: "gym.example.com",
+			})
+			acme, other, unknown := *e, *e, *e
+			acme.host = "acme.gym.example.com"
+			other.host = "other.gym.example.com:3333"
+			unknown.host = "unknown.gym.example.com"
+
+			// made up subdomains don't reach the storage
+			unknown.expect(t, "GET", "/classes/", "", http.StatusNotFound)
+			unknown.expect(t, "POST", "/classes", `{"name":"Pilates","start_date":"2020-01-01T00:00:00Z","end_date":"2020-01-31T00:00:00Z","capacity":10}`, http.StatusNotFound)
+			if tenants, err := s.Tenants(); err != nil || !reflect.DeepEqual(tenants, []string{"", "acme", "other"}) {
+				t.Errorf("got %v, %v, want the default tenant, acme and other", tenants, err)
+			}
+
+			// the seed belongs to the default tenant
+			e.expect(t, "GET", "/classes/PI0001", "", http.StatusOK)
+			acme.expect(t, "GET", "/classes/PI0001", "", http.StatusNotFound)
+
+			class, booking := &models.Class{}, &models.Booking{}
+			acme.decode(t, acme.expect(t, "POST", "/classes", `{"name":"Pilates","start_date":"2020-01-01T00:00:00Z","end_date":"2020-01-31T00:00:00Z","capacity":10}`, http.StatusCreated), class)
+			acme.decode(t, acme.expect(t, "POST", "/bookings", fmt.Sprintf(`{"customer":"Jane Doe","date":"2020-01-15T00:00:00Z","class":"%s"}`, class.ID), http.StatusCreated), booking)
+			acme.expect(t, "GET", "/classes/"+class.ID, "", http.StatusOK)
+
+			// other tenants can neither read nor write the classes and bookings of acme
+			for _, tenant := range []e2e{other, *e} {
+				tenant.expect(t, "GET", "/classes/"+class.ID, "", http.StatusNotFound)
+				tenant.expect(t, "PUT", "/classes/"+class.ID, `{"name":"Yoga","start_date":"2020-01-01T00:00:00Z","end_date":"2020-01-31T00:00:00Z","capacity":10}`, http.StatusNotFound)
+				tenant.expect(t, "DELETE", "/classes/"+class.ID, "", http.StatusNotFound)
+				tenant.expect(t, "GET", fmt.Sprintf("/bookings/%d", booking.ID), "", http.StatusNotFound)
+				tenant.expect(t, "POST", fmt.Sprintf("/bookings/%d/cancel", booking.ID), `{"by":"Jane Doe"}`, http.StatusNotFound)
+				tenant.expect(t, "DELETE", fmt.Sprintf("/bookings/%d", booking.ID), "", http.StatusNotFound)
+			}
+			bookings := []*models.Booking{}
+			other.decode(t, other.expect(t, "GET", "/bookings", "", http.StatusOK), &bookings)
+			if len(bookings) != 0 {
+				t.Errorf("got %+v, want no bookings", bookings)
+			}
+
+			acme.decode(t, acme.expect(t, "GET", fmt.Sprintf("/bookings/%d", booking.ID), "", http.StatusOK), booking)
+			if booking.Status != models.BookingConfirmed {
+				t.Errorf("got %s, want %s", booking.Status, models.BookingConfirmed)
+			}
+		})
+	}
+}
+
+// panickingStorage blows up when listing bookings
+type panickingStorage struct {
+	storage.Storage
+}
+
+func (s *panickingStorage) GetBookings() ([]*models.Booking, error) {
+	var bookings map[int]*models.Booking
+	bookings[0] = nil // nil map assignment
+	return nil, nil
+}
+
+func (s *panickingStorage) ForTenant(ID string) storage.Storage {
+	return &panickingStorage{s.Storage.ForTenant(ID)}
+}
+
+func TestEndToEndPanics(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			captureLog(t)
+			e := newE2E(t, &panickingStorage{newStorage(t)}, Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+
+			// the request fails with a JSON error but the server survives
+			body := map[string]string{}
+			e.decode(t, e.expect(t, "GET", "/bookings", "", http.StatusInternalServerError), &body)
+			if body["status"] != "Internal server error." {
+				t.Errorf("got %s, want %s", body["status"], "Internal server error.")
+			}
+			e.expect(t, "GET", "/classes/PI0001", "", http.StatusOK)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/*
+	Error types
+
+	Our API will send back meaningful errors in JSON format.
+*/
+
+// ErrResponse is our Error type for HTTP responses
+type ErrResponse struct {
+	Err            error  `json:"-"`               // low-level runtime error
+	HTTPStatusCode int    `json:"-"`               // http response status code
+	StatusText     string `json:"status"`          // user-level status message
+	ErrorText      string `json:"error,omitempty"` // application-level error message, for debugging
+
+	BannedUntil *time.Time `json:"banned_until,omitempty"` // when the customer can book again
+}
+
+// Render provides a representation of the error, same as valid responses
+func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, e.HTTPStatusCode)
+	return nil
+}
+
+// ErrRender is returned when we weren't able to provide a representation of the
+// resource requested
+func ErrRender(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 422,
+		StatusText:     "Error rendering response.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrInvalidRequest is for bad requests
+func ErrInvalidRequest(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 400,
+		StatusText:     "Invalid request.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrNotFound is the classic 404
+func ErrNotFound(err error) render.Renderer {
+	return &ErrResponse{
+		HTTPStatusCode: 404,
+		StatusText:     "Resource not found.",
+	}
+}
+
+// ErrConflict is for requests that aren't allowed by the current state of
+// the resource, e.g. cancelling a booking twice
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 409,
+		StatusText:     "Conflict with the current state of the resource.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrBanned is for customers who can't book classes until their ban expires
+func ErrBanned(err error, until time.Time) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 403,
+		StatusText:     "Customer banned from booking.",
+		ErrorText:      err.Error(),
+		BannedUntil:    &until,
+	}
+}
+
+// ErrInternal is for unexpected failures, the low-level error is kept out of
+// the response but the request ID lets us find the details in the logs
+func ErrInternal(err error, reqID string) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 500,
+		StatusText:     "Internal server error.",
+		ErrorText:      fmt.Sprintf("request ID %s", reqID),
+	}
+}
+
+// ErrForbidden is for requests the principal isn't allowed to send, e.g. to
+// the subdomain of another tenant
+func ErrForbidden(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 403,
+		StatusText:     "Forbidden.",
+		ErrorText:      err.Error(),
+	}
+}
@@ -0,0 +1,1113 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+// ListClasses handles GET requests at /classes, the list can be filtered
+// by the location of the room hosting the class with the `location` query
+// parameter
+func (s *Server) ListClasses(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	classes, err := s.store(r).GetClasses()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+	rooms, err := s.store(r).GetRooms()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+	locations := map[int]string{}
+	for _, room := range rooms {
+		locations[room.ID] = strconv.Itoa(room.Location)
+	}
+
+	// Get all the classes from the storage and render them one after the other
+	// using the RenderList helper from the Chi framework
+	location := r.URL.Query().Get("location")
+	for _, c := range classes {
+		if location != "" && locations[c.Room] != location {
+			continue
+		}
+		list = append(list, NewClassResponse(c))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CreateClass handles POST requests at /classes
+func (s *Server) CreateClass(w http.ResponseWriter, r *http.Request) {
+	data := &ClassPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	c := data.Class
+	if _, err := s.store(r).AddClass(c); err != nil {
+		renderClassError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewClassResponse(c))
+}
+
+// GetClass handles GET requests at /classes/<CLASS_ID>
+func (s *Server) GetClass(w http.ResponseWriter, r *http.Request) {
+	// get the Class object from the request context
+	class := r.Context().Value("class").(*models.Class)
+
+	if err := render.Render(w, r, NewClassResponse(class)); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// UpdateClass handles PUT requests at /classes/<CLASS_ID>
+func (s *Server) UpdateClass(w http.ResponseWriter, r *http.Request) {
+	// get the Class object from the request context
+	class := r.Context().Value("class").(*models.Class)
+
+	// render the payload to see if there's all we need to build a Class object.
+	// In a real-world scenario we could also enrich the object with some metadata
+	// if needed.
+	data := &ClassPayload{Class: class}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	class = data.Class
+
+	// persist the changes
+	if err := s.store(r).UpdateClass(class.ID, class); err != nil {
+		renderClassError(w, r, err)
+		return
+	}
+
+	// render the updated Class
+	render.Render(w, r, NewClassResponse(class))
+}
+
+// renderClassError tells apart classes clashing with another one taught by
+// the same instructor, or hosted by the same room, from invalid ones
+func renderClassError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	render.Render(w, r, ErrInvalidRequest(err))
+}
+
+// DeleteClass handles DELETE requests at /classes/<CLASS_ID>, the confirmed
+// bookings of the class are cancelled along with it
+func (s *Server) DeleteClass(w http.ResponseWriter, r *http.Request) {
+	// get the Class object from the request context
+	class := r.Context().Value("class").(*models.Class)
+
+	err := s.store(r).WithTx(r.Context(), func(tx storage.Tx) error {
+		// the studio is calling the class off, members aren't charged for it
+		waived := *class
+		waived.CancellationPolicy = models.CancellationPolicy{}
+		if err := tx.UpdateClass(class.ID, &waived); err != nil {
+			return err
+		}
+
+		bookings, err := tx.GetBookings()
+		if err != nil {
+			return err
+		}
+		for _, b := range bookings {
+			if b.Class != class.ID || b.Status != models.BookingConfirmed {
+				continue
+			}
+			if _, err := tx.CancelBooking(b.ID, "", "class deleted"); err != nil {
+				return err
+			}
+		}
+
+		return tx.DeleteClass(class.ID)
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		// deleted by another request in the meantime
+		render.Render(w, r, ErrNotFound(err))
+		return
+	case errors.Is(err, storage.ErrConflict):
+		render.Render(w, r, ErrConflict(err))
+		return
+	case err != nil:
+		reqID := middleware.GetReqID(r.Context())
+		log.Printf("[%s] deleting class %s: %v", reqID, class.ID, err)
+		render.Render(w, r, ErrInternal(err, reqID))
+		return
+	}
+
+	render.Render(w, r, NewClassResponse(class))
+}
+
+// RecordAttendance handles POST requests at
+// /classes/<CLASS_ID>/sessions/<DATE>/attendance, checking in all the bookings
+// listed in the payload at once, then it returns the bookings of the session
+func (s *Server) RecordAttendance(w http.ResponseWriter, r *http.Request) {
+	// get the Class object from the request context
+	class := r.Context().Value("class").(*models.Class)
+
+	date, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	data := &AttendancePayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	// sessions take place on a day in the time zone of the class
+	loc, err := storage.ClassLocation(s.store(r), class)
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+	inSession := func(b *models.Booking) bool {
+		return b.Class == class.ID && b.Date.In(loc).Format("2006-01-02") == date.Format("2006-01-02")
+	}
+	list := []render.Renderer{}
+	err = s.store(r).WithTx(r.Context(), func(tx storage.Tx) error {
+		for _, ID := range data.Attended {
+			b, err := tx.GetBooking(ID)
+			if err == nil && !inSession(b) {
+				err = fmt.Errorf("booking %d is not part of the session", ID)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := tx.CheckIn(ID); err != nil {
+				return err
+			}
+		}
+
+		bookings, err := tx.GetBookings()
+		if err != nil {
+			return err
+		}
+		list = list[:0] // fn might run again
+		zones := newZones(tx)
+		for _, b := range bookings {
+			if inSession(b) {
+				list = append(list, NewBookingResponse(zones.localize(b)))
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// ListBookings handles GET requests at /bookings, the list can be filtered
+// by status with the `status` query parameter
+func (s *Server) ListBookings(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	bookings, err := s.store(r).GetBookings()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	// Get all the bookings from the storage and render them one after the other
+	// using the RenderList helper from the Chi framework
+	status := r.URL.Query().Get("status")
+	zones := newZones(s.store(r))
+	for _, b := range bookings {
+		if status != "" && b.Status != status {
+			continue
+		}
+		list = append(list, NewBookingResponse(zones.localize(b)))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CreateBooking handles POST requests at /bookings
+func (s *Server) CreateBooking(w http.ResponseWriter, r *http.Request) {
+	data := &BookingPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	// persist booking
+	b := data.Booking
+	if _, err := s.store(r).AddBooking(b); err != nil {
+		renderBookingError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewBookingResponse(s.localize(r, b)))
+}
+
+// GetBooking handles GET requests at /bookings/<BOOKING_ID>
+func (s *Server) GetBooking(w http.ResponseWriter, r *http.Request) {
+	// get the Booking object from the request context
+	booking := r.Context().Value("booking").(*models.Booking)
+
+	if err := render.Render(w, r, NewBookingResponse(s.localize(r, booking))); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// UpdateBooking handles PUT requests at /bookings/<BOOKING_ID>, moving the
+// booking to another date, class or customer follows the rules of
+// CreateBooking
+func (s *Server) UpdateBooking(w http.ResponseWriter, r *http.Request) {
+	// get the Booking object from the request context
+	booking := r.Context().Value("booking").(*models.Booking)
+	stored := *booking
+
+	// render the payload to see if there's all we need to build a Booking object.
+	// In a real-world scenario we could also enrich the object with some metadata
+	// if needed.
+	data := &BookingPayload{Booking: booking}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	booking = data.Booking
+
+	// the status changes through its own endpoints only
+	booking.Status = stored.Status
+	booking.CancelledBy, booking.CancelledAt, booking.CancelReason = stored.CancelledBy, stored.CancelledAt, stored.CancelReason
+	booking.Cancellation, booking.CancelFee, booking.CreditForfeited = stored.Cancellation, stored.CancelFee, stored.CreditForfeited
+	booking.CheckedInAt = stored.CheckedInAt
+
+	// persist the changes, moving the booking is validated as booking anew
+	if err := s.store(r).UpdateBooking(booking.ID, booking); err != nil {
+		renderBookingError(w, r, err)
+		return
+	}
+
+	// render the updated Booking
+	render.Render(w, r, NewBookingResponse(s.localize(r, booking)))
+}
+
+// renderBookingError tells apart banned customers and bookings clashing with
+// the state of the class, e.g. full or closed, from invalid ones
+func renderBookingError(w http.ResponseWriter, r *http.Request, err error) {
+	banned := &storage.BannedError{}
+	if errors.As(err, &banned) {
+		render.Render(w, r, ErrBanned(err, banned.Ban.ExpiresAt))
+		return
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	render.Render(w, r, ErrInvalidRequest(err))
+}
+
+// CancelBooking handles POST requests at /bookings/<BOOKING_ID>/cancel, the
+// booking is kept along with who cancelled it, when and why. The response
+// tells wether the cancellation was free or late, according to the policy
+// of the class.
+func (s *Server) CancelBooking(w http.ResponseWriter, r *http.Request) {
+	// get the Booking object from the request context
+	booking := r.Context().Value("booking").(*models.Booking)
+
+	data := &CancelPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	booking, err := s.store(r).CancelBooking(booking.ID, data.By, data.Reason)
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewBookingResponse(s.localize(r, booking)))
+}
+
+// CheckIn handles POST requests at /bookings/<BOOKING_ID>/checkin, which are
+// only allowed within the check-in window around the booked time
+func (s *Server) CheckIn(w http.ResponseWriter, r *http.Request) {
+	// get the Booking object from the request context
+	booking := r.Context().Value("booking").(*models.Booking)
+
+	booking, err := s.store(r).CheckIn(booking.ID)
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewBookingResponse(s.localize(r, booking)))
+}
+
+// DeleteBooking handles DELETE requests at /bookings/<BOOKING_ID>, the booking
+// is removed for good so cancelling it should be preferred
+func (s *Server) DeleteBooking(w http.ResponseWriter, r *http.Request) {
+	// get the Booking object from the request context
+	booking := r.Context().Value("booking").(*models.Booking)
+
+	if err := s.store(r).DeleteBooking(booking.ID); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewBookingResponse(s.localize(r, booking)))
+}
+
+// ListBans handles GET requests at /bans, the list can be filtered with the
+// `active` query parameter
+func (s *Server) ListBans(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	bans, err := s.store(r).GetBans()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	// bans are in force according to the clock of the storage, the one
+	// checked when booking
+	active := r.URL.Query().Get("active")
+	now := s.store(r).Now()
+	for _, b := range bans {
+		if active != "" && strconv.FormatBool(b.Active(now)) != active {
+			continue
+		}
+		list = append(list, NewBanResponse(b))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// GetBan handles GET requests at /bans/<BAN_ID>
+func (s *Server) GetBan(w http.ResponseWriter, r *http.Request) {
+	// get the Ban object from the request context
+	ban := r.Context().Value("ban").(*models.Ban)
+
+	if err := render.Render(w, r, NewBanResponse(ban)); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// LiftBan handles POST requests at /bans/<BAN_ID>/lift, the customer can
+// book classes again right away
+func (s *Server) LiftBan(w http.ResponseWriter, r *http.Request) {
+	// get the Ban object from the request context
+	ban := r.Context().Value("ban").(*models.Ban)
+
+	data := &LiftPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	ban, err := s.store(r).LiftBan(ban.ID, data.By)
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewBanResponse(ban))
+}
+
+// ListInstructors handles GET requests at /instructors
+func (s *Server) ListInstructors(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	instructors, err := s.store(r).GetInstructors()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	for _, i := range instructors {
+		list = append(list, NewInstructorResponse(i))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CreateInstructor handles POST requests at /instructors
+func (s *Server) CreateInstructor(w http.ResponseWriter, r *http.Request) {
+	data := &InstructorPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	i := data.Instructor
+	if _, err := s.store(r).AddInstructor(i); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewInstructorResponse(i))
+}
+
+// GetInstructor handles GET requests at /instructors/<INSTRUCTOR_ID>
+func (s *Server) GetInstructor(w http.ResponseWriter, r *http.Request) {
+	// get the Instructor object from the request context
+	instructor := r.Context().Value("instructor").(*models.Instructor)
+
+	if err := render.Render(w, r, NewInstructorResponse(instructor)); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// UpdateInstructor handles PUT requests at /instructors/<INSTRUCTOR_ID>
+func (s *Server) UpdateInstructor(w http.ResponseWriter, r *http.Request) {
+	// get the Instructor object from the request context
+	instructor := r.Context().Value("instructor").(*models.Instructor)
+
+	data := &InstructorPayload{Instructor: instructor}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	instructor = data.Instructor
+
+	if err := s.store(r).UpdateInstructor(instructor.ID, instructor); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewInstructorResponse(instructor))
+}
+
+// DeleteInstructor handles DELETE requests at /instructors/<INSTRUCTOR_ID>,
+// instructors still teaching a class can't be deleted
+func (s *Server) DeleteInstructor(w http.ResponseWriter, r *http.Request) {
+	// get the Instructor object from the request context
+	instructor := r.Context().Value("instructor").(*models.Instructor)
+
+	err := s.store(r).DeleteInstructor(instructor.ID)
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewInstructorResponse(instructor))
+}
+
+// ListLocations handles GET requests at /locations
+func (s *Server) ListLocations(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	locations, err := s.store(r).GetLocations()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	for _, l := range locations {
+		list = append(list, NewLocationResponse(l))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CreateLocation handles POST requests at /locations
+func (s *Server) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	data := &LocationPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	location := data.Location
+	if _, err := s.store(r).AddLocation(location); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewLocationResponse(location))
+}
+
+// GetLocation handles GET requests at /locations/<LOCATION_ID>
+func (s *Server) GetLocation(w http.ResponseWriter, r *http.Request) {
+	// get the Location object from the request context
+	location := r.Context().Value("location").(*models.Location)
+
+	if err := render.Render(w, r, NewLocationResponse(location)); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// UpdateLocation handles PUT requests at /locations/<LOCATION_ID>
+func (s *Server) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	// get the Location object from the request context
+	location := r.Context().Value("location").(*models.Location)
+
+	data := &LocationPayload{Location: location}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	location = data.Location
+
+	if err := s.store(r).UpdateLocation(location.ID, location); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewLocationResponse(location))
+}
+
+// DeleteLocation handles DELETE requests at /locations/<LOCATION_ID>,
+// locations with rooms can't be deleted
+func (s *Server) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	// get the Location object from the request context
+	location := r.Context().Value("location").(*models.Location)
+
+	err := s.store(r).DeleteLocation(location.ID)
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewLocationResponse(location))
+}
+
+// ListRooms handles GET requests at /rooms, the list can be filtered
+// by location with the `location` query parameter
+func (s *Server) ListRooms(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	rooms, err := s.store(r).GetRooms()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	location := r.URL.Query().Get("location")
+	for _, room := range rooms {
+		if location != "" && strconv.Itoa(room.Location) != location {
+			continue
+		}
+		list = append(list, NewRoomResponse(room))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CreateRoom handles POST requests at /rooms, the location of the room
+// must exist
+func (s *Server) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	data := &RoomPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	room := data.Room
+	if _, err := s.store(r).AddRoom(room); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewRoomResponse(room))
+}
+
+// GetRoom handles GET requests at /rooms/<ROOM_ID>
+func (s *Server) GetRoom(w http.ResponseWriter, r *http.Request) {
+	// get the Room object from the request context
+	room := r.Context().Value("room").(*models.Room)
+
+	if err := render.Render(w, r, NewRoomResponse(room)); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// UpdateRoom handles PUT requests at /rooms/<ROOM_ID>
+func (s *Server) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	// get the Room object from the request context
+	room := r.Context().Value("room").(*models.Room)
+
+	data := &RoomPayload{Room: room}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	room = data.Room
+
+	if err := s.store(r).UpdateRoom(room.ID, room); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewRoomResponse(room))
+}
+
+// DeleteRoom handles DELETE requests at /rooms/<ROOM_ID>,
+// rooms hosting a class can't be deleted
+func (s *Server) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	// get the Room object from the request context
+	room := r.Context().Value("room").(*models.Room)
+
+	err := s.store(r).DeleteRoom(room.ID)
+	if errors.Is(err, storage.ErrConflict) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewRoomResponse(room))
+}
+
+// ListBlackouts handles GET requests at /blackouts
+func (s *Server) ListBlackouts(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	blackouts, err := s.store(r).GetBlackouts()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	for _, b := range blackouts {
+		list = append(list, NewBlackoutResponse(b))
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CreateBlackout handles POST requests at /blackouts, the confirmed bookings
+// falling within the blackout are cancelled and reported in the response
+// along with the customers affected
+func (s *Server) CreateBlackout(w http.ResponseWriter, r *http.Request) {
+	data := &BlackoutPayload{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	blackout := data.Blackout
+	cancelled, err := s.store(r).AddBlackout(blackout)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	resp := NewBlackoutResponse(blackout)
+	zones := newZones(s.store(r))
+	seen := map[string]bool{}
+	for _, b := range cancelled {
+		resp.Cancelled = append(resp.Cancelled, zones.localize(b))
+		if !seen[b.Customer] {
+			seen[b.Customer] = true
+			resp.Customers = append(resp.Customers, b.Customer)
+		}
+	}
+	sort.Strings(resp.Customers)
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, resp)
+}
+
+// GetBlackout handles GET requests at /blackouts/<BLACKOUT_ID>
+func (s *Server) GetBlackout(w http.ResponseWriter, r *http.Request) {
+	// get the Blackout object from the request context
+	blackout := r.Context().Value("blackout").(*models.Blackout)
+
+	if err := render.Render(w, r, NewBlackoutResponse(blackout)); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// DeleteBlackout handles DELETE requests at /blackouts/<BLACKOUT_ID>, the
+// bookings it cancelled aren't restored
+func (s *Server) DeleteBlackout(w http.ResponseWriter, r *http.Request) {
+	// get the Blackout object from the request context
+	blackout := r.Context().Value("blackout").(*models.Blackout)
+
+	if err := s.store(r).DeleteBlackout(blackout.ID); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.Render(w, r, NewBlackoutResponse(blackout))
+}
+
+// maxScheduleDays caps how far ahead the schedule of an instructor goes
+const maxScheduleDays = 366
+
+// GetSchedule handles GET requests at /instructors/<INSTRUCTOR_ID>/schedule,
+// listing the sessions the instructor teaches from today on. The `days` query
+// parameter tells how many days to cover, a week by default.
+func (s *Server) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	// get the Instructor object from the request context
+	instructor := r.Context().Value("instructor").(*models.Instructor)
+
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > maxScheduleDays {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("days must be between 1 and %d", maxScheduleDays)))
+			return
+		}
+	}
+
+	classes, err := s.store(r).GetClasses()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+	bookings, err := s.store(r).GetBookings()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+	blackouts, err := s.store(r).GetBlackouts()
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+
+	// the blackouts and the time zones of the classes are looked up once,
+	// the sessions are then matched in memory
+	taught := []*models.Class{}
+	closed := map[string]func(time.Time) *models.Blackout{}
+	zones := newZones(s.store(r))
+	for _, c := range classes {
+		if c.Instructor != instructor.ID {
+			continue
+		}
+		if closed[c.ID], err = storage.ClosedBy(s.store(r), c, blackouts); err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+		taught = append(taught, c)
+	}
+
+	// days are the ones of the time zone of each class, starting from its today
+	list := []render.Renderer{}
+	now := s.store(r).Now()
+	for d := 0; d < days; d++ {
+		for _, c := range taught {
+			loc := zones.of(c.ID)
+			y, m, dd := now.In(loc).Date()
+			day := time.Date(y, m, dd+d, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+			if day < c.StartDate.UTC().Format("2006-01-02") || day > c.EndDate.UTC().Format("2006-01-02") {
+				continue
+			}
+			if closed[c.ID](time.Date(y, m, dd+d, 0, 0, 0, 0, loc)) != nil {
+				continue
+			}
+			session := &models.Session{Class: c.ID, Name: c.Name, Date: day}
+			for _, b := range bookings {
+				if b.Class == c.ID && b.Status == models.BookingConfirmed && b.Date.In(loc).Format("2006-01-02") == session.Date {
+					session.Booked++
+				}
+			}
+			list = append(list, NewSessionResponse(session))
+		}
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+/*
+	Request/Response types.
+
+	For the sake of simplicity, we're using one struct to represent both
+	Requests and Responses for a single resource (Class and Booking) but
+	if needed these types can be further specialized.
+
+	Please note within the scope of this exercise Render and Bind methods
+	don't really need to do anything but we're ready to support more advanced
+	usage.
+*/
+
+// ClassPayload represents Request and Response payload for the Class resource
+type ClassPayload struct {
+	*models.Class
+}
+
+// NewClassResponse returns a ClassPayload object
+func NewClassResponse(class *models.Class) *ClassPayload {
+	return &ClassPayload{class}
+}
+
+// Render is a no-op for our use case
+func (cp *ClassPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Bind only ensures the Class object can be created in our use case
+func (cp *ClassPayload) Bind(r *http.Request) error {
+	// cp.Class is nil when there is no field in the request
+	if cp.Class == nil {
+		return errors.New("missing required Class object")
+	}
+	if cp.Cutoff < 0 {
+		return errors.New("the cancellation cutoff can't be negative")
+	}
+
+	return nil
+}
+
+// BookingPayload represents Request and Response payload for the Class resource
+type BookingPayload struct {
+	*models.Booking
+}
+
+// NewBookingResponse returns a ClassPayload object
+func NewBookingResponse(booking *models.Booking) *BookingPayload {
+	return &BookingPayload{booking}
+}
+
+// Render is a no-op for our use case
+func (cp *BookingPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Bind only ensures the Class object can be created in our use case
+func (cp *BookingPayload) Bind(r *http.Request) error {
+	// cp.Booking is nil when there is no field in the request
+	if cp.Booking == nil {
+		return errors.New("missing required Booking object")
+	}
+
+	return nil
+}
+
+// CancelPayload represents the Request payload to cancel a booking
+type CancelPayload struct {
+	By     string `json:"by"`
+	Reason string `json:"reason"`
+}
+
+// Bind is a no-op, the payload is validated against the OpenAPI document
+func (cp *CancelPayload) Bind(r *http.Request) error {
+	return nil
+}
+
+// BanPayload represents the Response payload for the Ban resource
+type BanPayload struct {
+	*models.Ban
+}
+
+// NewBanResponse returns a BanPayload object
+func NewBanResponse(ban *models.Ban) *BanPayload {
+	return &BanPayload{ban}
+}
+
+// Render is a no-op for our use case
+func (bp *BanPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// LiftPayload represents the Request payload to lift a ban
+type LiftPayload struct {
+	By string `json:"by"`
+}
+
+// Bind is a no-op, the payload is validated against the OpenAPI document
+func (lp *LiftPayload) Bind(r *http.Request) error {
+	return nil
+}
+
+// AttendancePayload represents the Request payload to record the attendance
+// of a session, listing the bookings to check in
+type AttendancePayload struct {
+	Attended []int `json:"attended"`
+}
+
+// Bind is a no-op, the payload is validated against the OpenAPI document
+func (ap *AttendancePayload) Bind(r *http.Request) error {
+	return nil
+}
+
+// InstructorPayload represents Request and Response payload for the
+// Instructor resource
+type InstructorPayload struct {
+	*models.Instructor
+}
+
+// NewInstructorResponse returns an InstructorPayload object
+func NewInstructorResponse(instructor *models.Instructor) *InstructorPayload {
+	return &InstructorPayload{instructor}
+}
+
+// Render is a no-op for our use case
+func (ip *InstructorPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Bind only ensures the Instructor object can be created in our use case
+func (ip *InstructorPayload) Bind(r *http.Request) error {
+	// ip.Instructor is nil when there is no field in the request
+	if ip.Instructor == nil {
+		return errors.New("missing required Instructor object")
+	}
+
+	return nil
+}
+
+// SessionPayload represents the Response payload for a session in the
+// schedule of an instructor
+type SessionPayload struct {
+	*models.Session
+}
+
+// NewSessionResponse returns a SessionPayload object
+func NewSessionResponse(session *models.Session) *SessionPayload {
+	return &SessionPayload{session}
+}
+
+// Render is a no-op for our use case
+func (sp *SessionPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// LocationPayload represents Request and Response payload for the Location resource
+type LocationPayload struct {
+	*models.Location
+}
+
+// NewLocationResponse returns a LocationPayload object
+func NewLocationResponse(location *models.Location) *LocationPayload {
+	return &LocationPayload{location}
+}
+
+// Render is a no-op for our use case
+func (lp *LocationPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Bind only ensures the Location object can be created in our use case
+func (lp *LocationPayload) Bind(r *http.Request) error {
+	// lp.Location is nil when there is no field in the request
+	if lp.Location == nil {
+		return errors.New("missing required Location object")
+	}
+
+	return nil
+}
+
+// RoomPayload represents Request and Response payload for the Room resource
+type RoomPayload struct {
+	*models.Room
+}
+
+// NewRoomResponse returns a RoomPayload object
+func NewRoomResponse(room *models.Room) *RoomPayload {
+	return &RoomPayload{room}
+}
+
+// Render is a no-op for our use case
+func (rp *RoomPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Bind only ensures the Room object can be created in our use case
+func (rp *RoomPayload) Bind(r *http.Request) error {
+	// rp.Room is nil when there is no field in the request
+	if rp.Room == nil {
+		return errors.New("missing required Room object")
+	}
+
+	return nil
+}
+
+// BlackoutPayload represents Request and Response payload for the Blackout
+// resource, the response to its creation lists the bookings it cancelled
+type BlackoutPayload struct {
+	*models.Blackout
+	Cancelled []*models.Booking `json:"cancelled_bookings,omitempty"`
+	Customers []string          `json:"affected_customers,omitempty"`
+}
+
+// NewBlackoutResponse returns a BlackoutPayload object
+func NewBlackoutResponse(blackout *models.Blackout) *BlackoutPayload {
+	return &BlackoutPayload{Blackout: blackout}
+}
+
+// Render is a no-op for our use case
+func (bp *BlackoutPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Bind only ensures the Blackout object can be created in our use case
+func (bp *BlackoutPayload) Bind(r *http.Request) error {
+	// bp.Blackout is nil when there is no field in the request
+	if bp.Blackout == nil {
+		return errors.New("missing required Blackout object")
+	}
+
+	return nil
+}
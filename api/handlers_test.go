@@ -1,10 +1,9 @@
-package main
+package api
 
 import (
 	"context"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 
@@ -19,9 +18,12 @@ import (
 	and another one that doesn't (TestListClasses)
 */
 
-func TestMain(m *testing.M) {
-	storage = s.NewSqliteStorage(":memory:")
-	os.Exit(m.Run())
+// newServer returns a Server on top of a fresh storage, closed at the end of the test
+func newServer(t *testing.T) *Server {
+	storage := s.NewSqliteStorage(":memory:", s.WithSeed(s.DefaultSeed()))
+	t.Cleanup(func() { storage.Close() })
+
+	return NewServer(storage)
 }
 
 func TestListClasses(t *testing.T) {
@@ -31,14 +33,14 @@ func TestListClasses(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(ListClasses)
+	handler := http.HandlerFunc(newServer(t).ListClasses)
 	handler.ServeHTTP(rr, req)
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("status code: got %v want %v", status, http.StatusOK)
 	}
 
 	// Check the response body is what we expect.
-	want := `[{"ID":"PI0001","name":"Pilates","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20},{"ID":"DA0001","name":"Dance+","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20},{"ID":"FB0001","name":"Full Body","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20},{"ID":"YO0001","name":"Yoga","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20}]`
+	want := `[{"ID":"PI0001","name":"Pilates","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20,"cancellation_policy":{"cutoff":"0s","fee":0,"forfeit_credit":false},"booking_window":{"opens_days_before":0,"closes_minutes_before":0}},{"ID":"DA0001","name":"Dance+","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20,"cancellation_policy":{"cutoff":"0s","fee":0,"forfeit_credit":false},"booking_window":{"opens_days_before":0,"closes_minutes_before":0}},{"ID":"FB0001","name":"Full Body","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20,"cancellation_policy":{"cutoff":"0s","fee":0,"forfeit_credit":false},"booking_window":{"opens_days_before":0,"closes_minutes_before":0}},{"ID":"YO0001","name":"Yoga","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20,"cancellation_policy":{"cutoff":"0s","fee":0,"forfeit_credit":false},"booking_window":{"opens_days_before":0,"closes_minutes_before":0}}]`
 	result := strings.TrimSpace(rr.Body.String())
 	if result != want {
 		t.Errorf("body: got %v want %v", result, want)
@@ -60,14 +62,15 @@ func TestGetClass(t *testing.T) {
 
 	// From here, it's like any other httptest logic
 	rr := httptest.NewRecorder()
-	handler := ClassCtx(http.HandlerFunc(GetClass))
+	srv := newServer(t)
+	handler := srv.ClassCtx(http.HandlerFunc(srv.GetClass))
 	handler.ServeHTTP(rr, req)
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("status code: got %v want %v", status, http.StatusOK)
 	}
 
 	// Check the response body is what we expect.
-	want := `{"ID":"PI0001","name":"Pilates","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20}`
+	want := `{"ID":"PI0001","name":"Pilates","start_date":"2020-01-29T00:00:00Z","end_date":"2020-02-28T00:00:00Z","capacity":20,"cancellation_policy":{"cutoff":"0s","fee":0,"forfeit_credit":false},"booking_window":{"opens_days_before":0,"closes_minutes_before":0}}`
 	result := strings.TrimSpace(rr.Body.String())
 	if result != want {
 		t.Errorf("body: got %v want %v", rr.Body.String(), want)
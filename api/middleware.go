@@ -0,0 +1,36 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Recoverer catches any panic happening down the chain, logging the stack
+// trace along with the request ID and replying with a 500 error in JSON
+// format. It's meant to be used after chi's RequestID middleware.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// the handler wants the connection to be dropped
+				panic(rvr)
+			}
+
+			reqID := middleware.GetReqID(r.Context())
+			log.Printf("[%s] panic serving %s %s: %v\n%s", reqID, r.Method, r.URL.Path, rvr, debug.Stack())
+
+			render.Render(w, r, ErrInternal(fmt.Errorf("%v", rvr), reqID))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
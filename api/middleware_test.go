@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+// captureLog redirects the standard logger for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	return &buf
+}
+
+// deletePanickingStorage fails to delete bookings the way DeleteBooking
+// used to, reading the booking from the context under the key of the Class
+// object
+type deletePanickingStorage struct {
+	storage.Storage
+}
+
+func (s *deletePanickingStorage) DeleteBooking(ID int) error {
+	ctx := context.WithValue(context.Background(), "booking", &models.Booking{ID: ID})
+	booking := ctx.Value("class").(*models.Booking)
+	return s.Storage.DeleteBooking(booking.ID)
+}
+
+func (s *deletePanickingStorage) ForTenant(ID string) storage.Storage {
+	return &deletePanickingStorage{s.Storage.ForTenant(ID)}
+}
+
+// serve sends the request straight to the router
+func serve(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRecoverer(t *testing.T) {
+	logs := captureLog(t)
+	s := &deletePanickingStorage{storage.NewVolatileStorage(storage.WithSeed(storage.DefaultSeed()))}
+	router := NewRouter(s, Options{})
+	if rr := serve(router, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("status code: got %v want %v, body: %s", rr.Code, http.StatusCreated, rr.Body)
+	}
+
+	// the panic within the route is turned into a JSON error
+	rr := serve(router, "DELETE", "/bookings/1", "")
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("content type: got %s want application/json", ct)
+	}
+
+	body := map[string]string{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON %s: %s", rr.Body.String(), err)
+	}
+	if body["status"] != "Internal server error." {
+		t.Errorf("got %s, want %s", body["status"], "Internal server error.")
+	}
+
+	// the request ID in the response lets us find the stack trace in the logs
+	reqID := strings.TrimPrefix(body["error"], "request ID ")
+	if reqID == "" || !strings.Contains(logs.String(), "["+reqID+"] panic serving DELETE /bookings/1") {
+		t.Errorf("request ID %q not found in logs: %s", reqID, logs.String())
+	}
+	if !strings.Contains(logs.String(), "interface conversion") {
+		t.Errorf("panic value not found in logs: %s", logs.String())
+	}
+
+	// the router keeps serving requests
+	if rr := serve(router, "GET", "/bookings/1", ""); rr.Code != http.StatusOK {
+		t.Errorf("status code: got %v want %v, body: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+}
+
+func TestRecovererAbortHandler(t *testing.T) {
+	abort := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if rvr := recover(); rvr != http.ErrAbortHandler {
+			t.Errorf("got %v, want %v", rvr, http.ErrAbortHandler)
+		}
+	}()
+	Recoverer(abort).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestDeleteBookingRegression(t *testing.T) {
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage(t)
+			defer s.Close()
+			router := NewRouter(s, Options{
+				ValidateResponses: func(r *http.Request, err error) {
+					t.Errorf("invalid response: %s", err)
+				},
+			})
+			if rr := serve(router, "POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z","class":"FB0001"}`); rr.Code != http.StatusCreated {
+				t.Fatalf("status code: got %v want %v, body: %s", rr.Code, http.StatusCreated, rr.Body)
+			}
+
+			// DeleteBooking replies with the deleted booking
+			rr := serve(router, "DELETE", "/bookings/1", "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status code: got %v want %v, body: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			booking := &models.Booking{}
+			if err := json.Unmarshal(rr.Body.Bytes(), booking); err != nil {
+				t.Fatalf("invalid JSON %s: %s", rr.Body.String(), err)
+			}
+			if booking.ID != 1 || booking.Customer != "Jane Doe" {
+				t.Errorf("got %+v, want booking 1 of Jane Doe", booking)
+			}
+
+			// and then it's gone
+			for _, path := range []string{"/bookings/1", "/bookings/42"} {
+				if rr := serve(router, "DELETE", path, ""); rr.Code != http.StatusNotFound {
+					t.Errorf("DELETE %s: status code: got %v want %v, body: %s", path, rr.Code, http.StatusNotFound, rr.Body)
+				}
+			}
+			if rr := serve(router, "GET", "/bookings/1", ""); rr.Code != http.StatusNotFound {
+				t.Errorf("status code: got %v want %v, body: %s", rr.Code, http.StatusNotFound, rr.Body)
+			}
+		})
+	}
+}
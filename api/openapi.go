@@ -0,0 +1,449 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+/*
+	OpenAPI validation
+
+	The API contract lives in openapi.json and it's embedded in the binary. The
+	types below only model the subset of the OpenAPI 3 specification we actually
+	use in the document, which is enough to validate path and query parameters,
+	request bodies and response bodies without pulling in a whole library.
+*/
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPI is the parsed API contract
+type OpenAPI struct {
+	Paths      map[string]*pathItem `json:"paths"`
+	Components struct {
+		Schemas   map[string]*schema   `json:"schemas"`
+		Responses map[string]*response `json:"responses"`
+	} `json:"components"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `json:"parameters"`
+	Get        *operation   `json:"get"`
+	Post       *operation   `json:"post"`
+	Put        *operation   `json:"put"`
+	Delete     *operation   `json:"delete"`
+}
+
+type operation struct {
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	Enum       []interface{}      `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	MinLength  *int               `json:"minLength"`
+	ReadOnly   bool               `json:"readOnly"`
+}
+
+// LoadOpenAPI parses the OpenAPI document embedded in the binary
+func LoadOpenAPI() (*OpenAPI, error) {
+	return ParseOpenAPI(openAPISpec)
+}
+
+// mustLoadOpenAPI is like LoadOpenAPI but panics on errors, the embedded
+// document is known to be valid
+func mustLoadOpenAPI() *OpenAPI {
+	doc, err := LoadOpenAPI()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// ParseOpenAPI parses an OpenAPI document in JSON format
+func ParseOpenAPI(data []byte) (*OpenAPI, error) {
+	doc := &OpenAPI{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	return doc, nil
+}
+
+// ValidateRequests returns a middleware checking path parameters, query
+// parameters and JSON bodies of incoming requests against the document.
+// Requests for paths the document doesn't know about are passed through
+// untouched, so that the router can answer them as usual.
+func (doc *OpenAPI) ValidateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		item, params := doc.findPath(routePath(r))
+		if item == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := item.operation(r.Method)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := doc.validateRequest(r, item, op, params); err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ValidateResponses returns a middleware checking the JSON bodies sent back by
+// the handlers against the document. It's meant to be used in tests, to catch
+// handlers drifting from the contract: every violation is reported through the
+// `report` callback and the response is forwarded to the client unchanged.
+func (doc *OpenAPI) ValidateResponses(report func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if err := doc.validateResponse(r, rec); err != nil {
+				report(r, err)
+			}
+		})
+	}
+}
+
+func (doc *OpenAPI) validateRequest(r *http.Request, item *pathItem, op *operation, pathParams map[string]string) error {
+	query := r.URL.Query()
+	params := append([]*parameter{}, item.Parameters...)
+	for _, p := range append(params, op.Parameters...) {
+		var value string
+		var found bool
+		switch p.In {
+		case "path":
+			value, found = pathParams[p.Name]
+		case "query":
+			if values, ok := query[p.Name]; ok && len(values) > 0 {
+				value, found = values[0], true
+			}
+		default:
+			continue
+		}
+
+		if !found {
+			if p.Required {
+				return fmt.Errorf("missing required %s parameter '%s'", p.In, p.Name)
+			}
+			continue
+		}
+		if err := doc.validateParameter(value, p); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	// handlers need to read the body again
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("missing required request body")
+		}
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("request body is not valid JSON: %w", err)
+	}
+
+	return doc.validate(value, media.Schema, "body", true)
+}
+
+func (doc *OpenAPI) validateParameter(raw string, p *parameter) error {
+	s := doc.resolve(p.Schema)
+	name := fmt.Sprintf("%s parameter '%s'", p.In, p.Name)
+	if s == nil {
+		return nil
+	}
+
+	var value interface{} = raw
+	switch s.Type {
+	case "integer":
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+		value = float64(n)
+	case "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", name)
+		}
+		value = n
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+		value = b
+	}
+
+	return doc.validate(value, s, name, true)
+}
+
+func (doc *OpenAPI) validateResponse(r *http.Request, rec *responseRecorder) error {
+	item, _ := doc.findPath(routePath(r))
+	if item == nil {
+		return nil
+	}
+	op := item.operation(r.Method)
+	if op == nil {
+		return nil
+	}
+
+	status := strconv.Itoa(rec.status)
+	resp, ok := op.Responses[status]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s %s: status %s is not documented", r.Method, r.URL.Path, status)
+	}
+	resp = doc.resolveResponse(resp)
+
+	media, ok := resp.Content["application/json"]
+	if !ok || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(rec.body.Bytes(), &value); err != nil {
+		return fmt.Errorf("%s %s: response body is not valid JSON: %w", r.Method, r.URL.Path, err)
+	}
+	if err := doc.validate(value, media.Schema, "response", false); err != nil {
+		return fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err)
+	}
+
+	return nil
+}
+
+// validate checks a decoded JSON value against a schema. Read-only properties
+// are set by the server, so they're ignored when validating requests.
+func (doc *OpenAPI) validate(value interface{}, s *schema, path string, request bool) error {
+	s = doc.resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s is missing required property '%s'", path, name)
+			}
+		}
+		for name, prop := range s.Properties {
+			v, ok := obj[name]
+			if !ok || (request && doc.resolve(prop).ReadOnly) {
+				continue
+			}
+			if err := doc.validate(v, prop, path+"."+name, request); err != nil {
+				return err
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		for i, v := range list {
+			if err := doc.validate(v, s.Items, fmt.Sprintf("%s[%d]", path, i), request); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters long", path, *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s must be a RFC 3339 date-time", path)
+			}
+		}
+		if s.Format == "date" {
+			if _, err := time.Parse("2006-01-02", str); err != nil {
+				return fmt.Errorf("%s must be a RFC 3339 full-date", path)
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok && s.Type == "number" {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Errorf("%s must be greater than or equal to %v", path, *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %v", path, s.Enum)
+	}
+
+	return nil
+}
+
+// findPath returns the path item matching the request path along with the
+// values of its path parameters, trailing slashes are ignored as the router does.
+func (doc *OpenAPI) findPath(path string) (*pathItem, map[string]string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for template, item := range doc.Paths {
+		tparts := strings.Split(strings.Trim(template, "/"), "/")
+		if len(tparts) != len(parts) {
+			continue
+		}
+
+		params := map[string]string{}
+		matches := true
+		for i, tp := range tparts {
+			if strings.HasPrefix(tp, "{") && strings.HasSuffix(tp, "}") {
+				params[tp[1:len(tp)-1]] = parts[i]
+			} else if tp != parts[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return item, params
+		}
+	}
+
+	return nil, nil
+}
+
+// routePath returns the path of the request relative to where the router is
+// mounted, which is what the document describes
+func routePath(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	return r.URL.Path
+}
+
+func (doc *OpenAPI) resolve(s *schema) *schema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return doc.resolve(doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")])
+}
+
+func (doc *OpenAPI) resolveResponse(r *response) *response {
+	if r.Ref == "" {
+		return r
+	}
+	return doc.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+}
+
+func (item *pathItem) operation(method string) *operation {
+	switch method {
+	case http.MethodGet:
+		return item.Get
+	case http.MethodPost:
+		return item.Post
+	case http.MethodPut:
+		return item.Put
+	case http.MethodDelete:
+		return item.Delete
+	}
+	return nil
+}
+
+// responseRecorder forwards everything to the wrapped ResponseWriter while
+// keeping a copy of the status code and the body for validation
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func TestValidateRequests(t *testing.T) {
+	doc, err := LoadOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// responses are validated as well, so that we notice handlers drifting
+	// from the contract while we test the requests
+	router := NewRouter(newServer(t).storage, Options{
+		OpenAPI: doc,
+		ValidateResponses: func(r *http.Request, err error) {
+			t.Errorf("invalid response: %s", err)
+		},
+	})
+
+	var tests = []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"GET", "/classes", "", http.StatusOK},
+		{"GET", "/classes/PI0001", "", http.StatusOK},
+		{"GET", "/classes/XX0000", "", http.StatusNotFound},
+		{"GET", "/bookings/abc", "", http.StatusBadRequest},
+		{"GET", "/bookings/0", "", http.StatusBadRequest},
+		{"POST", "/classes", "", http.StatusBadRequest},
+		{"POST", "/classes", `{"start_date":"2022-01-29T00:00:00Z","end_date":"2022-02-28T00:00:00Z"}`, http.StatusBadRequest},
+		{"POST", "/classes", `{"name":"Crossfit","start_date":"2022-01-29","end_date":"2022-02-28T00:00:00Z"}`, http.StatusBadRequest},
+		{"POST", "/classes", `{"name":"Crossfit","start_date":"2022-01-29T00:00:00Z","end_date":"2022-02-28T00:00:00Z","capacity":"100"}`, http.StatusBadRequest},
+		{"POST", "/bookings", `{"customer":"Jane Doe","date":"2020-01-30T00:00:00Z"}`, http.StatusBadRequest},
+		{"PUT", "/bookings/1", `[]`, http.StatusBadRequest},
+		{"POST", "/classes/PI0001/sessions/30-01-2020/attendance", `{"attended":[]}`, http.StatusBadRequest},
+		{"POST", "/classes/PI0001/sessions/2020-01-30/attendance", `{"attended":["1"]}`, http.StatusBadRequest},
+		{"POST", "/classes/PI0001/sessions/2020-01-30/attendance", `{"attended":[]}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("status code: got %v want %v, body: %s", status, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	doc, err := LoadOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a handler returning a Class that doesn't respect the contract
+	drifted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]interface{}{
+			"ID":         "PI0001",
+			"name":       "Pilates",
+			"start_date": "2020-01-29T00:00:00Z",
+			"end_date":   "2020-02-28T00:00:00Z",
+			"capacity":   "twenty",
+		})
+	})
+
+	var reported error
+	handler := doc.ValidateResponses(func(r *http.Request, err error) {
+		reported = err
+	})(drifted)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/classes/PI0001", nil))
+	if reported == nil {
+		t.Fatal("got nil, want error")
+	}
+	want := "GET /classes/PI0001: response.capacity must be an integer"
+	if reported.Error() != want {
+		t.Errorf("got %s, want %s", reported, want)
+	}
+}
+
+func TestValidateMountedRouter(t *testing.T) {
+	// the router is mounted under a prefix by another service
+	r := chi.NewRouter()
+	r.Mount("/gym", NewRouter(newServer(t).storage, Options{}))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/gym/bookings/abc", nil))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+/*
+	Tenants
+
+	Many studios can share the same deployment, each one being a tenant with
+	its own classes, bookings and so on. The tenant of a request is carried
+	by the authenticated principal or by the subdomain the request was sent
+	to, and every storage access is scoped to it.
+*/
+
+// Principal is who's sending the request, as established by the
+// authentication middleware of the service mounting the router
+type Principal struct {
+	Subject string
+	// Tenant the principal belongs to, empty for the default tenant
+	Tenant string
+}
+
+// WithPrincipal returns a copy of the context carrying the principal, it's
+// meant to be used by authentication middlewares
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, "principal", p)
+}
+
+// PrincipalFrom returns the principal carried by the context, if any
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value("principal").(Principal)
+	return p, ok
+}
+
+// TenantCtx scopes the storage to the tenant of the request, which is the
+// one of the principal if any, or the subdomain of `domain` the request was
+// sent to. Principals sending requests to the subdomain of another tenant
+// get a 403, requests for tenants unknown to the storage get a 404.
+func (s *Server) TenantCtx(domain string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := subdomain(r.Host, domain)
+			if p, ok := PrincipalFrom(r.Context()); ok {
+				if tenant != "" && tenant != p.Tenant {
+					err := fmt.Errorf("%s doesn't belong to tenant %s", p.Subject, tenant)
+					render.Render(w, r, ErrForbidden(err))
+					return
+				}
+				tenant = p.Tenant
+			}
+
+			known, err := s.storage.HasTenant(tenant)
+			if err != nil {
+				reqID := middleware.GetReqID(r.Context())
+				log.Printf("[%s] looking up tenant %s: %v", reqID, tenant, err)
+				render.Render(w, r, ErrInternal(err, reqID))
+				return
+			}
+			if !known {
+				render.Render(w, r, ErrNotFound(fmt.Errorf("unknown tenant %s", tenant)))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "storage", s.storage.ForTenant(tenant))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// subdomain returns the first label of `host` when it's a subdomain of
+// `domain`, e.g. "acme" for "acme.gym.example.com" within "gym.example.com"
+func subdomain(host, domain string) string {
+	if domain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(host)
+	prefix := strings.TrimSuffix(host, "."+strings.ToLower(domain))
+	if prefix == host || strings.Contains(prefix, ".") {
+		return ""
+	}
+	return prefix
+}
+
+// store returns the storage scoped to the tenant of the request, falling
+// back to the default tenant when TenantCtx wasn't used
+func (s *Server) store(r *http.Request) storage.Storage {
+	if st, ok := r.Context().Value("storage").(storage.Storage); ok {
+		return st
+	}
+	return s.storage
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+func TestSubdomain(t *testing.T) {
+	tests := []struct {
+		host, domain, want string
+	}{
+		{"acme.gym.example.com", "gym.example.com", "acme"},
+		{"ACME.gym.example.com:3333", "gym.example.com", "acme"},
+		{"gym.example.com", "gym.example.com", ""},
+		{"a.b.gym.example.com", "gym.example.com", ""},
+		{"acme.example.org", "gym.example.com", ""},
+		{"acme.gym.example.com", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := subdomain(tt.host, tt.domain); got != tt.want {
+			t.Errorf("%s within %s: got %q, want %q", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestTenantCtxPrincipal(t *testing.T) {
+	s := storage.NewVolatileStorage()
+	defer s.Close()
+	c := &models.Class{Name: "Pilates"}
+	s.ForTenant("acme").AddClass(c)
+
+	// authenticates everybody as a member of acme
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithPrincipal(r.Context(), Principal{Subject: "jane", Tenant: "acme"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	router := auth(NewRouter(s, Options{Domain: "gym.example.com"}))
+
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{"gym.example.com", http.StatusOK},
+		{"acme.gym.example.com", http.StatusOK},
+		{"other.gym.example.com", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/classes/"+c.ID, nil)
+		req.Host = tt.host
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: status code: got %v want %v", tt.host, rr.Code, tt.status)
+		}
+	}
+}
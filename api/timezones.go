@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+// zones looks up the time zones of the classes, remembering them for the
+// duration of a request
+type zones struct {
+	tx    storage.Tx
+	cache map[string]*time.Location
+}
+
+func newZones(tx storage.Tx) *zones {
+	return &zones{tx: tx, cache: map[string]*time.Location{}}
+}
+
+// of returns the time zone of the class `ID`, UTC when the class is gone
+func (z *zones) of(ID string) *time.Location {
+	if loc, ok := z.cache[ID]; ok {
+		return loc
+	}
+
+	loc := time.UTC
+	if c, err := z.tx.GetClass(ID); err == nil {
+		if classLoc, err := storage.ClassLocation(z.tx, c); err == nil {
+			loc = classLoc
+		}
+	}
+	z.cache[ID] = loc
+	return loc
+}
+
+// localize returns a copy of the booking whose times are in the time zone
+// of its class, so that they're rendered with the right offset
+func (z *zones) localize(b *models.Booking) *models.Booking {
+	loc := z.of(b.Class)
+	local := *b
+	local.Date = b.Date.In(loc)
+	if b.CancelledAt != nil {
+		at := b.CancelledAt.In(loc)
+		local.CancelledAt = &at
+	}
+	if b.CheckedInAt != nil {
+		at := b.CheckedInAt.In(loc)
+		local.CheckedInAt = &at
+	}
+	return &local
+}
+
+// localize returns a copy of the booking of the request whose times are in
+// the time zone of its class
+func (s *Server) localize(r *http.Request, b *models.Booking) *models.Booking {
+	return newZones(s.store(r)).localize(b)
+}
@@ -0,0 +1,278 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/api"
+	"github.com/masci/go-rest-playground/models"
+	s "github.com/masci/go-rest-playground/storage"
+)
+
+// newTestServer runs the real router on a fresh storage and returns a
+// Client talking to it
+func newTestServer(t *testing.T) *Client {
+	storage := s.NewVolatileStorage(s.WithSeed(s.DefaultSeed()))
+	srv := httptest.NewServer(api.NewRouter(storage, api.Options{
+		ValidateResponses: func(r *http.Request, err error) {
+			t.Errorf("invalid response: %s", err)
+		},
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		storage.Close()
+	})
+
+	return New(srv.URL)
+}
+
+func TestClientClasses(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	created, err := c.CreateClass(ctx, &models.Class{
+		Name:      "Crossfit",
+		StartDate: time.Date(2022, 1, 29, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC),
+		Capacity:  100,
+	})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if created.ID == "" {
+		t.Errorf("got empty ID")
+	}
+
+	classes, err := c.ListClasses(ctx)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if len(classes) != 5 {
+		t.Errorf("got %d, want %d", len(classes), 5)
+	}
+
+	created.Capacity = 50
+	if _, err := c.UpdateClass(ctx, created); err != nil {
+		t.Errorf("got %s", err)
+	}
+	class, err := c.GetClass(ctx, created.ID)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if class.Capacity != 50 {
+		t.Errorf("got %d, want %d", class.Capacity, 50)
+	}
+
+	if err := c.DeleteClass(ctx, created.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if _, err := c.GetClass(ctx, created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %s", err, ErrNotFound)
+	}
+}
+
+func TestClientBookings(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	// the class isn't available on that date
+	_, err := c.CreateBooking(ctx, &models.Booking{
+		Customer: "Jane Doe",
+		Class:    "FB0001",
+		Date:     time.Date(2022, 1, 30, 0, 0, 0, 0, time.UTC),
+	})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("got %v, want %s", err, ErrInvalidRequest)
+	}
+
+	created, err := c.CreateBooking(ctx, &models.Booking{
+		Customer: "Jane Doe",
+		Class:    "FB0001",
+		Date:     time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	created.Customer = "John Doe"
+	if _, err := c.UpdateBooking(ctx, created); err != nil {
+		t.Errorf("got %s", err)
+	}
+	booking, err := c.GetBooking(ctx, created.ID)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if booking.Customer != "John Doe" {
+		t.Errorf("got %s, want %s", booking.Customer, "John Doe")
+	}
+
+	bookings, err := c.ListBookings(ctx)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if len(bookings) != 1 {
+		t.Errorf("got %d, want %d", len(bookings), 1)
+	}
+
+	if _, err := c.GetBooking(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %s", err, ErrNotFound)
+	}
+
+	// the session took place long ago
+	if _, err := c.CheckIn(ctx, created.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want %s", err, ErrConflict)
+	}
+	session, err := c.RecordAttendance(ctx, "FB0001", created.Date, nil)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if len(session) != 1 || session[0].ID != created.ID {
+		t.Errorf("got %+v, want booking %d only", session, created.ID)
+	}
+
+	cancelled, err := c.CancelBooking(ctx, created.ID, "front desk", "sick")
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if cancelled.Status != models.BookingCancelled || cancelled.CancelledBy != "front desk" {
+		t.Errorf("got %s by %s, want %s by %s", cancelled.Status, cancelled.CancelledBy, models.BookingCancelled, "front desk")
+	}
+	if _, err := c.CancelBooking(ctx, created.ID, "front desk", ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want %s", err, ErrConflict)
+	}
+}
+
+func TestClientInstructors(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	created, err := c.CreateInstructor(ctx, &models.Instructor{Name: "Jane"})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	created.Email = "jane@example.com"
+	if _, err := c.UpdateInstructor(ctx, created); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if instructor, err := c.GetInstructor(ctx, created.ID); err != nil || instructor.Email != created.Email {
+		t.Errorf("got %v %v", instructor, err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	class, err := c.CreateClass(ctx, &models.Class{Name: "Pilates", StartDate: today, EndDate: today, Instructor: created.ID})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if _, err := c.CreateClass(ctx, class); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want %s", err, ErrConflict)
+	}
+	sessions, err := c.GetSchedule(ctx, created.ID, 7)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(sessions) != 1 || sessions[0].Class != class.ID {
+		t.Errorf("got %+v, want one session of %s", sessions, class.ID)
+	}
+
+	if err := c.DeleteInstructor(ctx, created.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want %s", err, ErrConflict)
+	}
+	if instructors, _ := c.ListInstructors(ctx); len(instructors) != 1 {
+		t.Errorf("got %d, want %d", len(instructors), 1)
+	}
+}
+
+func TestClientLocationsAndRooms(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	location, err := c.CreateLocation(ctx, &models.Location{Name: "Downtown"})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	room, err := c.CreateRoom(ctx, &models.Room{Location: location.ID, Name: "Studio 1", Capacity: 20})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	room.Capacity = 10
+	if _, err := c.UpdateRoom(ctx, room); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if stored, err := c.GetRoom(ctx, room.ID); err != nil || stored.Capacity != 10 {
+		t.Errorf("got %v %v", stored, err)
+	}
+
+	class, err := c.CreateClass(ctx, &models.Class{
+		Name:      "Crossfit",
+		StartDate: time.Date(2022, 1, 29, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC),
+		Room:      room.ID,
+	})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	classes, err := c.ListClassesAt(ctx, location.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(classes) != 1 || classes[0].ID != class.ID {
+		t.Errorf("got %+v, want %s only", classes, class.ID)
+	}
+
+	if err := c.DeleteLocation(ctx, location.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want %s", err, ErrConflict)
+	}
+	if rooms, _ := c.ListRooms(ctx); len(rooms) != 1 {
+		t.Errorf("got %d, want %d", len(rooms), 1)
+	}
+}
+
+func TestClientBlackouts(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	booked, err := c.CreateBooking(ctx, &models.Booking{
+		Customer: "Jane Doe",
+		Class:    "YO0001",
+		Date:     time.Date(2020, 2, 10, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	blackout, cancelled, err := c.CreateBlackout(ctx, &models.Blackout{
+		StartDate: time.Date(2020, 2, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2020, 2, 11, 0, 0, 0, 0, time.UTC),
+		Reason:    "floor works",
+		Class:     "YO0001",
+	})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != booked.ID || cancelled[0].CancelReason != "floor works" {
+		t.Errorf("got %+v, want booking %d cancelled", cancelled, booked.ID)
+	}
+	if stored, err := c.GetBlackout(ctx, blackout.ID); err != nil || stored.Class != "YO0001" {
+		t.Errorf("got %v %v", stored, err)
+	}
+
+	_, err = c.CreateBooking(ctx, &models.Booking{
+		Customer: "Jane Doe",
+		Class:    "YO0001",
+		Date:     time.Date(2020, 2, 11, 9, 0, 0, 0, time.UTC),
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want %s", err, ErrConflict)
+	}
+
+	if err := c.DeleteBlackout(ctx, blackout.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if blackouts, _ := c.ListBlackouts(ctx); len(blackouts) != 0 {
+		t.Errorf("got %d, want %d", len(blackouts), 0)
+	}
+}
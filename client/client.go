@@ -0,0 +1,458 @@
+// The client package implements a Go client for the REST API exposed by
+// the service, reusing the same data model.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+// Client talks to a running instance of the service
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	retries    int
+	backoff    time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used to perform the requests, by default
+// http.DefaultClient is used.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithToken sets the credentials sent along with every request as a
+// bearer token in the Authorization header
+func WithToken(token string) Option {
+	return func(cl *Client) {
+		cl.token = token
+	}
+}
+
+// WithRetries sets how many times a request is retried when it fails because
+// of a network error or a server error. The delay between attempts starts from
+// `backoff` and doubles at every retry. Only idempotent requests are retried.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(cl *Client) {
+		cl.retries = retries
+		cl.backoff = backoff
+	}
+}
+
+// New creates a Client for the service listening at `baseURL`,
+// e.g. http://localhost:3333
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retries:    2,
+		backoff:    100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+/*
+	Classes
+*/
+
+// ListClasses returns all the classes
+func (c *Client) ListClasses(ctx context.Context) ([]*models.Class, error) {
+	classes := []*models.Class{}
+	err := c.do(ctx, http.MethodGet, "/classes", nil, &classes)
+	return classes, err
+}
+
+// ListClassesAt returns the classes hosted in the rooms of the location with
+// the given ID
+func (c *Client) ListClassesAt(ctx context.Context, location int) ([]*models.Class, error) {
+	classes := []*models.Class{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/classes?location=%d", location), nil, &classes)
+	return classes, err
+}
+
+// GetClass returns the class with the given ID
+func (c *Client) GetClass(ctx context.Context, ID string) (*models.Class, error) {
+	class := &models.Class{}
+	err := c.do(ctx, http.MethodGet, "/classes/"+ID, nil, class)
+	return class, err
+}
+
+// CreateClass adds a new class and returns it as stored by the service,
+// identifier included
+func (c *Client) CreateClass(ctx context.Context, class *models.Class) (*models.Class, error) {
+	created := &models.Class{}
+	err := c.do(ctx, http.MethodPost, "/classes", class, created)
+	return created, err
+}
+
+// UpdateClass replaces the class identified by `class.ID`
+func (c *Client) UpdateClass(ctx context.Context, class *models.Class) (*models.Class, error) {
+	updated := &models.Class{}
+	err := c.do(ctx, http.MethodPut, "/classes/"+class.ID, class, updated)
+	return updated, err
+}
+
+// DeleteClass removes the class with the given ID
+func (c *Client) DeleteClass(ctx context.Context, ID string) error {
+	return c.do(ctx, http.MethodDelete, "/classes/"+ID, nil, nil)
+}
+
+/*
+	Bookings
+*/
+
+// ListBookings returns all the bookings
+func (c *Client) ListBookings(ctx context.Context) ([]*models.Booking, error) {
+	bookings := []*models.Booking{}
+	err := c.do(ctx, http.MethodGet, "/bookings", nil, &bookings)
+	return bookings, err
+}
+
+// GetBooking returns the booking with the given ID
+func (c *Client) GetBooking(ctx context.Context, ID int) (*models.Booking, error) {
+	booking := &models.Booking{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/bookings/%d", ID), nil, booking)
+	return booking, err
+}
+
+// CreateBooking books a class and returns the booking as stored by the service,
+// identifier included
+func (c *Client) CreateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	created := &models.Booking{}
+	err := c.do(ctx, http.MethodPost, "/bookings", booking, created)
+	return created, err
+}
+
+// UpdateBooking replaces the booking identified by `booking.ID`
+func (c *Client) UpdateBooking(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	updated := &models.Booking{}
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/bookings/%d", booking.ID), booking, updated)
+	return updated, err
+}
+
+// CancelBooking cancels the booking with the given ID, recording who
+// cancelled it and why, and returns it as stored by the service
+func (c *Client) CancelBooking(ctx context.Context, ID int, by, reason string) (*models.Booking, error) {
+	cancelled := &models.Booking{}
+	in := map[string]string{"by": by, "reason": reason}
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/bookings/%d/cancel", ID), in, cancelled)
+	return cancelled, err
+}
+
+// CheckIn marks the booking with the given ID as attended, it fails with
+// ErrConflict outside of the check-in window
+func (c *Client) CheckIn(ctx context.Context, ID int) (*models.Booking, error) {
+	attended := &models.Booking{}
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/bookings/%d/checkin", ID), nil, attended)
+	return attended, err
+}
+
+// RecordAttendance checks in the given bookings of the session of the class
+// taking place on `date`, and returns all the bookings of the session
+func (c *Client) RecordAttendance(ctx context.Context, classID string, date time.Time, attended []int) ([]*models.Booking, error) {
+	if attended == nil {
+		attended = []int{} // null isn't a list for the service
+	}
+	bookings := []*models.Booking{}
+	in := map[string][]int{"attended": attended}
+	path := fmt.Sprintf("/classes/%s/sessions/%s/attendance", classID, date.Format("2006-01-02"))
+	err := c.do(ctx, http.MethodPost, path, in, &bookings)
+	return bookings, err
+}
+
+// DeleteBooking removes the booking with the given ID for good, cancelling
+// it should be preferred
+func (c *Client) DeleteBooking(ctx context.Context, ID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/bookings/%d", ID), nil, nil)
+}
+
+/*
+	Bans
+*/
+
+// ListBans returns all the bans, including the ones no longer in force
+func (c *Client) ListBans(ctx context.Context) ([]*models.Ban, error) {
+	bans := []*models.Ban{}
+	err := c.do(ctx, http.MethodGet, "/bans", nil, &bans)
+	return bans, err
+}
+
+// LiftBan ends the ban with the given ID, recording who lifted it
+func (c *Client) LiftBan(ctx context.Context, ID int, by string) (*models.Ban, error) {
+	lifted := &models.Ban{}
+	in := map[string]string{"by": by}
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/bans/%d/lift", ID), in, lifted)
+	return lifted, err
+}
+
+/*
+	Locations
+*/
+
+// ListLocations returns all the locations
+func (c *Client) ListLocations(ctx context.Context) ([]*models.Location, error) {
+	locations := []*models.Location{}
+	err := c.do(ctx, http.MethodGet, "/locations", nil, &locations)
+	return locations, err
+}
+
+// GetLocation returns the location with the given ID
+func (c *Client) GetLocation(ctx context.Context, ID int) (*models.Location, error) {
+	location := &models.Location{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/locations/%d", ID), nil, location)
+	return location, err
+}
+
+// CreateLocation adds a new location and returns it as stored by the
+// service, identifier included
+func (c *Client) CreateLocation(ctx context.Context, location *models.Location) (*models.Location, error) {
+	created := &models.Location{}
+	err := c.do(ctx, http.MethodPost, "/locations", location, created)
+	return created, err
+}
+
+// UpdateLocation replaces the location identified by `location.ID`
+func (c *Client) UpdateLocation(ctx context.Context, location *models.Location) (*models.Location, error) {
+	updated := &models.Location{}
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/locations/%d", location.ID), location, updated)
+	return updated, err
+}
+
+// DeleteLocation removes the location with the given ID, it fails with
+// ErrConflict while the location has rooms
+func (c *Client) DeleteLocation(ctx context.Context, ID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/locations/%d", ID), nil, nil)
+}
+
+/*
+	Rooms
+*/
+
+// ListRooms returns all the rooms
+func (c *Client) ListRooms(ctx context.Context) ([]*models.Room, error) {
+	rooms := []*models.Room{}
+	err := c.do(ctx, http.MethodGet, "/rooms", nil, &rooms)
+	return rooms, err
+}
+
+// GetRoom returns the room with the given ID
+func (c *Client) GetRoom(ctx context.Context, ID int) (*models.Room, error) {
+	room := &models.Room{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/rooms/%d", ID), nil, room)
+	return room, err
+}
+
+// CreateRoom adds a new room and returns it as stored by the
+// service, identifier included
+func (c *Client) CreateRoom(ctx context.Context, room *models.Room) (*models.Room, error) {
+	created := &models.Room{}
+	err := c.do(ctx, http.MethodPost, "/rooms", room, created)
+	return created, err
+}
+
+// UpdateRoom replaces the room identified by `room.ID`
+func (c *Client) UpdateRoom(ctx context.Context, room *models.Room) (*models.Room, error) {
+	updated := &models.Room{}
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/rooms/%d", room.ID), room, updated)
+	return updated, err
+}
+
+// DeleteRoom removes the room with the given ID, it fails with
+// ErrConflict while the room hosts some class
+func (c *Client) DeleteRoom(ctx context.Context, ID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/rooms/%d", ID), nil, nil)
+}
+
+/*
+	Blackouts
+*/
+
+// ListBlackouts returns all the blackouts
+func (c *Client) ListBlackouts(ctx context.Context) ([]*models.Blackout, error) {
+	blackouts := []*models.Blackout{}
+	err := c.do(ctx, http.MethodGet, "/blackouts", nil, &blackouts)
+	return blackouts, err
+}
+
+// GetBlackout returns the blackout with the given ID
+func (c *Client) GetBlackout(ctx context.Context, ID int) (*models.Blackout, error) {
+	blackout := &models.Blackout{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/blackouts/%d", ID), nil, blackout)
+	return blackout, err
+}
+
+// CreateBlackout closes the studio and returns the blackout as stored by the
+// service, identifier included, along with the bookings it cancelled
+func (c *Client) CreateBlackout(ctx context.Context, blackout *models.Blackout) (*models.Blackout, []*models.Booking, error) {
+	created := &struct {
+		*models.Blackout
+		Cancelled []*models.Booking `json:"cancelled_bookings"`
+	}{Blackout: &models.Blackout{}}
+	err := c.do(ctx, http.MethodPost, "/blackouts", blackout, created)
+	return created.Blackout, created.Cancelled, err
+}
+
+// DeleteBlackout removes the blackout with the given ID, the bookings it
+// cancelled stay cancelled
+func (c *Client) DeleteBlackout(ctx context.Context, ID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/blackouts/%d", ID), nil, nil)
+}
+
+/*
+	Instructors
+*/
+
+// ListInstructors returns all the instructors
+func (c *Client) ListInstructors(ctx context.Context) ([]*models.Instructor, error) {
+	instructors := []*models.Instructor{}
+	err := c.do(ctx, http.MethodGet, "/instructors", nil, &instructors)
+	return instructors, err
+}
+
+// GetInstructor returns the instructor with the given ID
+func (c *Client) GetInstructor(ctx context.Context, ID int) (*models.Instructor, error) {
+	instructor := &models.Instructor{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/instructors/%d", ID), nil, instructor)
+	return instructor, err
+}
+
+// CreateInstructor adds a new instructor and returns it as stored by the
+// service, identifier included
+func (c *Client) CreateInstructor(ctx context.Context, instructor *models.Instructor) (*models.Instructor, error) {
+	created := &models.Instructor{}
+	err := c.do(ctx, http.MethodPost, "/instructors", instructor, created)
+	return created, err
+}
+
+// UpdateInstructor replaces the instructor identified by `instructor.ID`
+func (c *Client) UpdateInstructor(ctx context.Context, instructor *models.Instructor) (*models.Instructor, error) {
+	updated := &models.Instructor{}
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/instructors/%d", instructor.ID), instructor, updated)
+	return updated, err
+}
+
+// DeleteInstructor removes the instructor with the given ID, it fails with
+// ErrConflict while the instructor teaches some class
+func (c *Client) DeleteInstructor(ctx context.Context, ID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/instructors/%d", ID), nil, nil)
+}
+
+// GetSchedule returns the sessions taught by the instructor with the given
+// ID over the next `days` days, today included
+func (c *Client) GetSchedule(ctx context.Context, ID, days int) ([]*models.Session, error) {
+	sessions := []*models.Session{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/instructors/%d/schedule?days=%d", ID, days), nil, &sessions)
+	return sessions, err
+}
+
+/*
+	Others
+*/
+
+// Ping checks the service is up and running
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/ping", nil, nil)
+}
+
+// do sends the request and decodes the JSON response into `out`, when
+// given. Failed attempts are retried according to the client configuration,
+// waiting between them unless the context is done.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	retries := c.retries
+	if method == http.MethodPost {
+		// creating resources isn't idempotent
+		retries = 0
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.send(ctx, method, path, body, out)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// send performs a single attempt, returning whether it's worth retrying in
+// case of errors
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}) (bool, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// no point in retrying if the caller gave up
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.StatusText == "" {
+			apiErr.StatusText = http.StatusText(resp.StatusCode)
+		}
+		return resp.StatusCode >= 500, apiErr
+	}
+
+	if out == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+func TestRetries(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+
+	// two retries are enough to get through
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if attempts != 3 {
+		t.Errorf("got %d, want %d", attempts, 3)
+	}
+
+	// one retry isn't
+	attempts = 0
+	c = New(srv.URL, WithRetries(1, time.Millisecond))
+	err := c.Ping(context.Background())
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("got %v, want %s", err, ErrServer)
+	}
+	if attempts != 2 {
+		t.Errorf("got %d, want %d", attempts, 2)
+	}
+}
+
+func TestNoRetriesOnCreate(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(5, time.Millisecond))
+	if _, err := c.CreateBooking(context.Background(), nil); err == nil {
+		t.Errorf("got nil, want error")
+	}
+	if attempts != 1 {
+		t.Errorf("got %d, want %d", attempts, 1)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// the context expires while the client waits before retrying
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := New(srv.URL, WithRetries(10, time.Second))
+	err := c.Ping(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %s", err, context.DeadlineExceeded)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":"Invalid request.","error":"missing required Class object"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	_, err := c.CreateClass(context.Background(), nil)
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %d, want %d", apiErr.StatusCode, http.StatusBadRequest)
+	}
+	if apiErr.ErrorText != "missing required Class object" {
+		t.Errorf("got %s, want %s", apiErr.ErrorText, "missing required Class object")
+	}
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("got %v, want %s", err, ErrInvalidRequest)
+	}
+}
+
+func TestBannedResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"status":"Customer banned from booking.","banned_until":"2020-02-14T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	_, err := c.CreateBooking(context.Background(), &models.Booking{})
+
+	var apiErr *Error
+	if !errors.Is(err, ErrBanned) || !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want %s", err, ErrBanned)
+	}
+	if want := time.Date(2020, 2, 14, 0, 0, 0, 0, time.UTC); !apiErr.BannedUntil.Equal(want) {
+		t.Errorf("got %s, want %s", apiErr.BannedUntil, want)
+	}
+}
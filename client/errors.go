@@ -0,0 +1,61 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrInvalidRequest is matched by errors returned when the API rejected
+	// the request, e.g. errors.Is(err, client.ErrInvalidRequest)
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrNotFound is matched by errors returned when the resource requested
+	// doesn't exist
+	ErrNotFound = errors.New("resource not found")
+	// ErrConflict is matched by errors returned when the request isn't
+	// allowed by the current state of the resource, e.g. the class is full
+	ErrConflict = errors.New("conflict")
+	// ErrBanned is matched by errors returned when the customer is banned
+	// from booking, Error.BannedUntil tells until when
+	ErrBanned = errors.New("banned")
+	// ErrServer is matched by errors returned when the API failed to serve
+	// the request
+	ErrServer = errors.New("server error")
+)
+
+// Error is returned for any non-successful response from the API. It carries
+// the content of the error payload sent back by the service.
+type Error struct {
+	StatusCode int    `json:"-"`               // http response status code
+	StatusText string `json:"status"`          // user-level status message
+	ErrorText  string `json:"error,omitempty"` // application-level error message
+
+	BannedUntil *time.Time `json:"banned_until,omitempty"` // set along with ErrBanned
+}
+
+func (e *Error) Error() string {
+	if e.ErrorText != "" {
+		return fmt.Sprintf("%d %s %s", e.StatusCode, e.StatusText, e.ErrorText)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, e.StatusText)
+}
+
+// Unwrap maps the status code of the response to one of the sentinel errors
+// exported by this package
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusForbidden && e.BannedUntil != nil:
+		return ErrBanned
+	case e.StatusCode >= 500:
+		return ErrServer
+	case e.StatusCode >= 400:
+		return ErrInvalidRequest
+	}
+	return nil
+}
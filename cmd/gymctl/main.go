@@ -0,0 +1,383 @@
+// gymctl is a command line client for the service, meant to be used by the
+// studio staff to manage classes and bookings.
+//
+// Usage:
+//
+//	gymctl classes list|create|update|delete [flags]
+//	gymctl bookings list|create|cancel|checkin [flags]
+//
+// The server URL and the credentials can be passed with the -server and
+// -token flags, or through the GYMCTL_SERVER and GYMCTL_TOKEN environment
+// variables.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/masci/go-rest-playground/client"
+	"github.com/masci/go-rest-playground/models"
+)
+
+const usage = `Usage:
+  gymctl classes list|create|update|delete [flags]
+  gymctl bookings list|create|cancel|checkin [flags]
+
+Run 'gymctl <resource> <command> -h' to see the flags of each command.
+`
+
+// errUsage is returned when the command line doesn't make sense, the
+// details have already been printed to the user
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code of the program
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 2 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var cmd func(*command) error
+	switch args[0] + " " + args[1] {
+	case "classes list":
+		cmd = listClasses
+	case "classes create":
+		cmd = createClass
+	case "classes update":
+		cmd = updateClass
+	case "classes delete":
+		cmd = deleteClass
+	case "bookings list":
+		cmd = listBookings
+	case "bookings create":
+		cmd = createBooking
+	case "bookings cancel":
+		cmd = cancelBooking
+	case "bookings checkin":
+		cmd = checkIn
+	default:
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	c := newCommand(args[0]+" "+args[1], args[2:], stdout, stderr)
+	if err := cmd(c); err != nil {
+		if err != errUsage {
+			fmt.Fprintln(stderr, "Error:", err)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// command holds the flags shared by all the commands along with the
+// ones specific to each of them
+type command struct {
+	flags  *flag.FlagSet
+	args   []string
+	stdout io.Writer
+	stderr io.Writer
+
+	server string
+	token  string
+	output string
+}
+
+func newCommand(name string, args []string, stdout, stderr io.Writer) *command {
+	c := &command{
+		flags:  flag.NewFlagSet("gymctl "+name, flag.ContinueOnError),
+		args:   args,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	c.flags.SetOutput(stderr)
+
+	server := os.Getenv("GYMCTL_SERVER")
+	if server == "" {
+		server = "http://localhost:3333"
+	}
+	c.flags.StringVar(&c.server, "server", server, "URL of the server, defaults to $GYMCTL_SERVER")
+	c.flags.StringVar(&c.token, "token", os.Getenv("GYMCTL_TOKEN"), "credentials for the server, defaults to $GYMCTL_TOKEN")
+	c.flags.StringVar(&c.output, "output", "table", "output format: [table|json|csv]")
+
+	return c
+}
+
+// parse parses the command line, expecting exactly `positional` arguments
+// before the flags
+func (c *command) parse(positional ...string) ([]string, error) {
+	if len(c.args) < len(positional) {
+		fmt.Fprintf(c.stderr, "missing required argument(s): %v\n", positional[len(c.args):])
+		return nil, errUsage
+	}
+	values := c.args[:len(positional)]
+
+	if err := c.flags.Parse(c.args[len(positional):]); err != nil {
+		return nil, errUsage
+	}
+	if c.flags.NArg() > 0 {
+		fmt.Fprintf(c.stderr, "unexpected argument(s): %v\n", c.flags.Args())
+		return nil, errUsage
+	}
+	if _, ok := formatters[c.output]; !ok {
+		fmt.Fprintf(c.stderr, "unknown output format: %s\n", c.output)
+		return nil, errUsage
+	}
+
+	return values, nil
+}
+
+func (c *command) client() *client.Client {
+	return client.New(c.server, client.WithToken(c.token))
+}
+
+/*
+	Classes
+*/
+
+func listClasses(c *command) error {
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+
+	classes, err := c.client().ListClasses(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return printClasses(c.stdout, c.output, classes...)
+}
+
+func createClass(c *command) error {
+	class := &models.Class{}
+	start, end := classFlags(c, class)
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+	if err := parseDates(class, *start, *end); err != nil {
+		return err
+	}
+
+	created, err := c.client().CreateClass(context.Background(), class)
+	if err != nil {
+		return err
+	}
+
+	return printClasses(c.stdout, c.output, created)
+}
+
+func updateClass(c *command) error {
+	update := &models.Class{}
+	start, end := classFlags(c, update)
+	args, err := c.parse("ID")
+	if err != nil {
+		return err
+	}
+	if err := parseDates(update, *start, *end); err != nil {
+		return err
+	}
+
+	// only the fields passed on the command line are changed
+	cl := c.client()
+	class, err := cl.GetClass(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	c.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			class.Name = update.Name
+		case "start":
+			class.StartDate = update.StartDate
+		case "end":
+			class.EndDate = update.EndDate
+		case "capacity":
+			class.Capacity = update.Capacity
+		case "instructor":
+			class.Instructor = update.Instructor
+		case "room":
+			class.Room = update.Room
+		case "time-zone":
+			class.TimeZone = update.TimeZone
+		case "time":
+			class.StartTime = update.StartTime
+		case "duration":
+			class.Duration = update.Duration
+		case "cancel-cutoff":
+			class.Cutoff = update.Cutoff
+		case "cancel-fee":
+			class.Fee = update.Fee
+		case "forfeit-credit":
+			class.ForfeitCredit = update.ForfeitCredit
+		case "booking-opens":
+			class.OpensDays = update.OpensDays
+		case "booking-closes":
+			class.ClosesMinutes = update.ClosesMinutes
+		}
+	})
+
+	updated, err := cl.UpdateClass(context.Background(), class)
+	if err != nil {
+		return err
+	}
+
+	return printClasses(c.stdout, c.output, updated)
+}
+
+func deleteClass(c *command) error {
+	args, err := c.parse("ID")
+	if err != nil {
+		return err
+	}
+
+	if err := c.client().DeleteClass(context.Background(), args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Class %s deleted\n", args[0])
+	return nil
+}
+
+// classFlags binds the flags describing a class, dates are returned as
+// strings because they're parsed after the command line
+func classFlags(c *command, class *models.Class) (*string, *string) {
+	c.flags.StringVar(&class.Name, "name", "", "name of the class")
+	c.flags.IntVar(&class.Capacity, "capacity", 0, "capacity of the class")
+	c.flags.IntVar(&class.Instructor, "instructor", 0, "ID of the instructor teaching the class, 0 for none")
+	c.flags.IntVar(&class.Room, "room", 0, "ID of the room hosting the class, 0 for none")
+	c.flags.StringVar(&class.TimeZone, "time-zone", "", "IANA time zone of the class, e.g. Europe/Rome")
+	c.flags.StringVar(&class.StartTime, "time", "", "time of day the sessions start at, e.g. 18:30")
+	c.flags.DurationVar((*time.Duration)(&class.Duration), "duration", 0, "how long the sessions last, e.g. 1h, 0 for the whole day")
+	c.flags.DurationVar((*time.Duration)(&class.Cutoff), "cancel-cutoff", 0, "how long before the class cancelling a booking is late, e.g. 12h")
+	c.flags.IntVar(&class.Fee, "cancel-fee", 0, "fee for late cancellations, in cents")
+	c.flags.BoolVar(&class.ForfeitCredit, "forfeit-credit", false, "wether late cancellations forfeit the credit")
+	c.flags.IntVar(&class.OpensDays, "booking-opens", 0, "how many days before a session booking opens, 0 for no limit")
+	c.flags.IntVar(&class.ClosesMinutes, "booking-closes", 0, "how many minutes before a session booking closes, 0 for no limit")
+	start := c.flags.String("start", "", "first day of the class, e.g. 2022-01-29")
+	end := c.flags.String("end", "", "last day of the class, e.g. 2022-02-28")
+
+	return start, end
+}
+
+func parseDates(class *models.Class, start, end string) error {
+	var err error
+	if start != "" {
+		if class.StartDate, err = parseTime(start); err != nil {
+			return err
+		}
+	}
+	if end != "" {
+		if class.EndDate, err = parseTime(end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Bookings
+*/
+
+func listBookings(c *command) error {
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+
+	bookings, err := c.client().ListBookings(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return printBookings(c.stdout, c.output, bookings...)
+}
+
+func createBooking(c *command) error {
+	booking := &models.Booking{}
+	c.flags.StringVar(&booking.Customer, "customer", "", "name of the customer")
+	c.flags.StringVar(&booking.Class, "class", "", "ID of the class to book")
+	date := c.flags.String("date", "", "date of the booking, e.g. 2022-01-30")
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+
+	var err error
+	if booking.Date, err = parseTime(*date); err != nil {
+		return err
+	}
+
+	created, err := c.client().CreateBooking(context.Background(), booking)
+	if err != nil {
+		return err
+	}
+
+	return printBookings(c.stdout, c.output, created)
+}
+
+func cancelBooking(c *command) error {
+	by := os.Getenv("USER")
+	if by == "" {
+		by = "gymctl"
+	}
+	c.flags.StringVar(&by, "by", by, "who is cancelling the booking, defaults to $USER")
+	reason := c.flags.String("reason", "", "why the booking is cancelled")
+	args, err := c.parse("ID")
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid booking ID: %s", args[0])
+	}
+
+	if _, err := c.client().CancelBooking(context.Background(), id, by, *reason); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Booking %d cancelled\n", id)
+	return nil
+}
+
+func checkIn(c *command) error {
+	args, err := c.parse("ID")
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid booking ID: %s", args[0])
+	}
+
+	booking, err := c.client().CheckIn(context.Background(), id)
+	if err != nil {
+		return err
+	}
+
+	return printBookings(c.stdout, c.output, booking)
+}
+
+// parseTime accepts both full timestamps and plain dates, the latter
+// being easier to type
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC 3339", value)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/masci/go-rest-playground/api"
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// newTestServer runs the real API router in-process on top of a fresh
+// in-memory storage, the golden files hold what the service answers and
+// every response is checked against the contract
+func newTestServer(t *testing.T) string {
+	s := storage.NewVolatileStorage(storage.WithSeed(storage.DefaultSeed()))
+	srv := httptest.NewServer(api.NewRouter(s, api.Options{
+		ValidateResponses: func(r *http.Request, err error) {
+			t.Errorf("invalid response: %s", err)
+		},
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		s.Close()
+	})
+
+	return srv.URL
+}
+
+func TestCommands(t *testing.T) {
+	var tests = []struct {
+		golden string
+		args   []string
+		code   int
+	}{
+		{"classes_list_table", []string{"classes", "list"}, 0},
+		{"classes_list_json", []string{"classes", "list", "-output", "json"}, 0},
+		{"classes_list_csv", []string{"classes", "list", "-output", "csv"}, 0},
+		{"classes_update", []string{"classes", "update", "PI0001", "-capacity", "30", "-end", "2020-03-31"}, 0},
+		{"classes_delete", []string{"classes", "delete", "YO0001"}, 0},
+		{"classes_delete_not_found", []string{"classes", "delete", "XX0000"}, 1},
+		{"bookings_create", []string{"bookings", "create", "-customer", "Jane Doe", "-class", "FB0001", "-date", "2020-01-30"}, 0},
+		{"bookings_create_invalid", []string{"bookings", "create", "-customer", "Jane Doe", "-class", "FB0001", "-date", "2022-01-30"}, 1},
+		{"bookings_list_csv", []string{"bookings", "list", "-output", "csv"}, 0},
+		{"bookings_checkin_closed", []string{"bookings", "checkin", "1"}, 1},
+		{"bookings_cancel", []string{"bookings", "cancel", "1"}, 0},
+		{"unknown_output", []string{"bookings", "list", "-output", "xml"}, 1},
+		{"unknown_command", []string{"bookings", "archive"}, 2},
+	}
+
+	// commands run in sequence against the same server
+	server := newTestServer(t)
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var out bytes.Buffer
+			code := run(append(tt.args, "-server", server), &out, &out)
+			if code != tt.code {
+				t.Errorf("exit code: got %d, want %d", code, tt.code)
+			}
+
+			// the address of the server changes at every run
+			got := strings.ReplaceAll(out.String(), server, "SERVER")
+
+			golden := filepath.Join("testdata", tt.golden+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output: got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCreateClass(t *testing.T) {
+	server := newTestServer(t)
+
+	// the ID is random so there's no golden file for this one
+	var out bytes.Buffer
+	code := run([]string{"classes", "create", "-server", server, "-output", "json",
+		"-name", "Crossfit", "-start", "2022-01-29", "-end", "2022-02-28", "-capacity", "100"}, &out, &out)
+	if code != 0 {
+		t.Fatalf("exit code: got %d, want %d, output: %s", code, 0, out.String())
+	}
+
+	classes := []*models.Class{}
+	if err := json.Unmarshal(out.Bytes(), &classes); err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 1 || classes[0].Name != "Crossfit" || classes[0].ID == "" {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
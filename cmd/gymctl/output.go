@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+// formatter prints a list of records, `data` is what the json format
+// outputs while the other formats use the header and the rows
+type formatter func(w io.Writer, data interface{}, header []string, rows [][]string) error
+
+var formatters = map[string]formatter{
+	"table": printTable,
+	"json":  printJSON,
+	"csv":   printCSV,
+}
+
+func printClasses(w io.Writer, format string, classes ...*models.Class) error {
+	// the API doesn't guarantee any ordering
+	sort.Slice(classes, func(i, j int) bool { return classes[i].ID < classes[j].ID })
+
+	header := []string{"ID", "NAME", "START", "END", "CAPACITY"}
+	rows := [][]string{}
+	for _, c := range classes {
+		rows = append(rows, []string{
+			c.ID,
+			c.Name,
+			formatTime(c.StartDate),
+			formatTime(c.EndDate),
+			strconv.Itoa(c.Capacity),
+		})
+	}
+
+	return formatters[format](w, classes, header, rows)
+}
+
+func printBookings(w io.Writer, format string, bookings ...*models.Booking) error {
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].ID < bookings[j].ID })
+
+	header := []string{"ID", "CUSTOMER", "CLASS", "DATE", "STATUS"}
+	rows := [][]string{}
+	for _, b := range bookings {
+		rows = append(rows, []string{
+			strconv.Itoa(b.ID),
+			b.Customer,
+			b.Class,
+			formatTime(b.Date),
+			b.Status,
+		})
+	}
+
+	return formatters[format](w, bookings, header, rows)
+}
+
+func printTable(w io.Writer, data interface{}, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, data interface{}, header []string, rows [][]string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+func printCSV(w io.Writer, data interface{}, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+
+	return cw.Error()
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
@@ -4,102 +4,108 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"math/rand"
+	"log"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for the images lacking the IANA database
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/render"
+	"github.com/masci/go-rest-playground/api"
 	s "github.com/masci/go-rest-playground/storage"
 )
 
 var dbFile = flag.String("use-db", "", "Path to the SQLite database file")
-var storage s.Storage
+var boltFile = flag.String("use-bolt", "", "Path to the bbolt database file")
+var seedFile = flag.String("seed", "", "Path to a JSON or YAML file with the initial data")
+var snapshotFile = flag.String("snapshot", "", "Path to the snapshot file of the in-memory storage")
+var snapshotEvery = flag.Duration("snapshot-interval", time.Minute, "How often the in-memory storage is saved to the snapshot file")
+var noShowEvery = flag.Duration("no-show-interval", time.Minute, "How often unchecked bookings are marked as no-shows")
+var noShowLimit = flag.Int("no-show-limit", 3, "Customers with more no-shows than this within -no-show-window are banned from booking")
+var noShowWindow = flag.Duration("no-show-window", 30*24*time.Hour, "Period the no-shows are counted over, 0 disables the bans")
+var banDuration = flag.Duration("ban-duration", 14*24*time.Hour, "How long customers with too many no-shows are banned from booking")
+var domain = flag.String("domain", "", "Domain whose subdomains select the tenant, e.g. gym.example.com")
+var tenants = flag.String("tenants", "", "Comma separated tenants to provision, requests for other subdomains of -domain get a 404")
 
 func main() {
 	flag.Parse()
-	rand.Seed(time.Now().UnixNano()) // this is needed by the storage package to create IDs
+
+	// load the initial data, if any
+	opts := []s.Option{s.WithNoShowPenalty(*noShowLimit, *noShowWindow, *banDuration)}
+	if *seedFile != "" {
+		seed, err := s.LoadSeed(*seedFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, s.WithSeed(seed))
+		fmt.Println("Loading initial data from", *seedFile)
+	}
 
 	// init the storage system according to user's preferences
+	var storage s.Storage
 	if *dbFile != "" {
-		storage = s.NewSqliteStorage(*dbFile)
+		storage = s.NewSqliteStorage(*dbFile, opts...)
 		fmt.Println("Using SQLite database", *dbFile)
+	} else if *boltFile != "" {
+		storage = s.NewBoltStorage(*boltFile, opts...)
+		fmt.Println("Using bbolt database", *boltFile)
+	} else if *snapshotFile != "" {
+		var err error
+		storage, err = s.OpenVolatileStorage(append(opts, s.WithSnapshot(*snapshotFile, *snapshotEvery))...)
+		if err != nil {
+			log.Fatalf("Unable to restore the snapshot: %v", err)
+		}
+		fmt.Println("Using in-memory storage, data will be saved to", *snapshotFile)
 	} else {
-		storage = s.NewVolatileStorage()
+		storage = s.NewVolatileStorage(opts...)
 		fmt.Println("Using in-memory storage, all data will be lost on exit")
 	}
-	defer storage.Close()
-
-	// create routes
-	r := chi.NewRouter()
-
-	// healthcheck for containerized deployments
-	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("pong"))
-	})
-
-	// classes
-	r.Route("/classes", func(r chi.Router) {
-		r.Get("/", ListClasses)
-		r.Post("/", CreateClass)
-		r.Route("/{classID}", func(r chi.Router) {
-			r.Use(ClassCtx)
-			r.Get("/", GetClass)
-			r.Put("/", UpdateClass)
-			r.Delete("/", DeleteClass)
-		})
-	})
-
-	// bookings
-	r.Route("/bookings", func(r chi.Router) {
-		r.Get("/", ListBookings)
-		r.Post("/", CreateBooking)
-		r.Route("/{bookingID}", func(r chi.Router) {
-			r.Use(BookingCtx)
-			r.Get("/", GetBooking)
-			r.Put("/", UpdateBooking)
-			r.Delete("/", DeleteBooking)
-		})
-	})
-
-	// fire up the web server
-	http.ListenAndServe(":3333", r)
-}
-
-// ClassCtx loads and injects a Class object into the request.
-// In case the Class cannot be found, it returns a 404
-func ClassCtx(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		class, err := storage.GetClass(chi.URLParam(r, "classID"))
-		if err != nil {
-			render.Render(w, r, ErrNotFound(err))
-			return
+	// provision the tenants, subdomains are matched lowercase
+	for _, tenant := range strings.Split(*tenants, ",") {
+		if tenant = strings.TrimSpace(tenant); tenant == "" {
+			continue
 		}
-
-		ctx := context.WithValue(r.Context(), "class", class)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-// BookingCtx loads and injects a Class object into the request.
-// In case the Class cannot be found, it returns a 404
-func BookingCtx(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id, err := strconv.Atoi(chi.URLParam(r, "bookingID"))
-		if err != nil {
-			render.Render(w, r, ErrRender(err))
-			return
+		if err := storage.AddTenant(strings.ToLower(tenant)); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		booking, err := storage.GetBooking(id)
-		if err != nil {
-			render.Render(w, r, ErrNotFound(err))
-			return
-		}
+	// mark the no-shows in background
+	jobs, stopJobs := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
+	go func() {
+		s.WatchNoShows(jobs, storage, *noShowEvery)
+		close(jobsDone)
+	}()
 
-		ctx := context.WithValue(r.Context(), "booking", booking)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	// fire up the web server
+	srv := &http.Server{
+		Addr:    ":3333",
+		Handler: api.NewRouter(storage, api.Options{Domain: *domain}),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// wait for the signal to shut down, then let the pending requests
+	// complete before closing the storage
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Shutdown:", err)
+	}
+	stopJobs()
+	<-jobsDone
+	if err := storage.Close(); err != nil {
+		log.Println("Closing the storage:", err)
+	}
 }
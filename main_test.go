@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestBuildWithoutCgo makes sure everything builds where cgo isn't
+// available, and that SQLite works there too on the pure Go driver
+func TestBuildWithoutCgo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the whole module takes a while")
+	}
+
+	goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	for _, args := range [][]string{
+		{"build", "./..."},
+		{"test", "-count=1", "-run", "TestSqlite", "./storage"},
+	} {
+		cmd := exec.Command(goBin, args...)
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("go %v: got %s\n%s", args, err, out)
+		}
+	}
+}
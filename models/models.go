@@ -3,6 +3,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -13,12 +14,166 @@ type Class struct {
 	StartDate time.Time `json:"start_date" db:"start_date"`
 	EndDate   time.Time `json:"end_date" db:"end_date"`
 	Capacity  int       `json:"capacity" db:"capacity"`
+
+	// ID of the instructor teaching the class, if any
+	Instructor int `json:"instructor,omitempty" db:"instructor"`
+	// ID of the room hosting the class, if any
+	Room int `json:"room,omitempty" db:"room"`
+	// IANA time zone of the class, e.g. Europe/Rome, defaults to the one of
+	// the location of the room or to UTC
+	TimeZone string `json:"time_zone,omitempty" db:"time_zone"`
+	// Time of day the sessions start at, HH:MM in the time zone of the
+	// class, and how long they last. Sessions of classes without a
+	// duration take up their whole day.
+	StartTime string   `json:"start_time,omitempty" db:"start_time"`
+	Duration  Duration `json:"duration,omitempty" db:"duration"`
+
+	CancellationPolicy `json:"cancellation_policy"`
+	BookingWindow      `json:"booking_window"`
+}
+
+// Instructor represents a person teaching classes
+type Instructor struct {
+	ID    int
+	Name  string `json:"name" db:"name"`
+	Email string `json:"email,omitempty" db:"email"`
+}
+
+// Location is a studio of the chain
+type Location struct {
+	ID      int
+	Name    string `json:"name" db:"name"`
+	Address string `json:"address,omitempty" db:"address"`
+	// IANA time zone of the classes taking place at the location
+	TimeZone string `json:"time_zone,omitempty" db:"time_zone"`
+}
+
+// Room is where classes take place within a location, a capacity of zero
+// means no limit
+type Room struct {
+	ID       int
+	Location int    `json:"location" db:"location"`
+	Name     string `json:"name" db:"name"`
+	Capacity int    `json:"capacity" db:"capacity"`
+}
+
+// Blackout closes the studio from StartDate to EndDate, both days included:
+// everywhere, or at a location or for a class only
+type Blackout struct {
+	ID        int
+	StartDate time.Time `json:"start_date" db:"start_date"`
+	EndDate   time.Time `json:"end_date" db:"end_date"`
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+
+	// ID of the location or of the class closed, the blackout is global
+	// when both are empty
+	Location int    `json:"location,omitempty" db:"location"`
+	Class    string `json:"class,omitempty" db:"class"`
+}
+
+// Session is a class taking place on a given day, along with how many
+// confirmed bookings it has
+type Session struct {
+	Class  string `json:"class"`
+	Name   string `json:"name"`
+	Date   string `json:"date"` // YYYY-MM-DD
+	Booked int    `json:"booked"`
+}
+
+// CancellationPolicy tells what members are charged when they cancel a
+// booking less than `Cutoff` before the class, a zero cutoff means that
+// cancelling is always free
+type CancellationPolicy struct {
+	Cutoff        Duration `json:"cutoff" db:"cancel_cutoff"`
+	Fee           int      `json:"fee" db:"cancel_fee"` // in cents
+	ForfeitCredit bool     `json:"forfeit_credit" db:"cancel_forfeit_credit"`
+}
+
+// BookingWindow tells when members can book a session of the class: from
+// `OpensDays` days before it starts, and until `ClosesMinutes` minutes before.
+// Zero means no limit.
+type BookingWindow struct {
+	OpensDays     int `json:"opens_days_before" db:"booking_opens_days"`
+	ClosesMinutes int `json:"closes_minutes_before" db:"booking_closes_minutes"`
+}
+
+// Duration is a time.Duration represented in JSON as a string, e.g. "12h"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
 }
 
+// Booking statuses: bookings are confirmed when created, then they're either
+// cancelled or, once the class took place, attended or missed
+const (
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+	BookingAttended  = "attended"
+	BookingNoShow    = "no_show"
+)
+
+// Cancellations are free, or late when they happen past the cutoff of the
+// cancellation policy of the class
+const (
+	CancelFree = "free"
+	CancelLate = "late"
+)
+
 // Booking represents a customer's booking for a certain class
 type Booking struct {
 	ID       int
 	Date     time.Time `json:"date" db:"date"`
 	Customer string    `json:"customer" db:"customer"`
 	Class    string    `json:"class" db:"class"`
+	Status   string    `json:"status" db:"status"`
+
+	// who cancelled the booking, when and why
+	CancelledBy  string     `json:"cancelled_by,omitempty" db:"cancelled_by"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CancelReason string     `json:"cancel_reason,omitempty" db:"cancel_reason"`
+
+	// wether the cancellation was free or late, and what the member was
+	// charged for it
+	Cancellation    string `json:"cancellation,omitempty" db:"cancellation"`
+	CancelFee       int    `json:"cancel_fee,omitempty" db:"cancel_fee"`
+	CreditForfeited bool   `json:"credit_forfeited,omitempty" db:"credit_forfeited"`
+
+	// when the customer showed up
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty" db:"checked_in_at"`
+}
+
+// Ban bars a customer from booking classes until it expires, or until it's
+// lifted by the staff
+type Ban struct {
+	ID        int
+	Customer  string    `json:"customer" db:"customer"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+
+	// who lifted the ban and when, if it didn't expire on its own
+	LiftedBy string     `json:"lifted_by,omitempty" db:"lifted_by"`
+	LiftedAt *time.Time `json:"lifted_at,omitempty" db:"lifted_at"`
+}
+
+// Active tells wether the ban is still in force at the given time
+func (b *Ban) Active(now time.Time) bool {
+	return b.LiftedAt == nil && now.Before(b.ExpiresAt)
 }
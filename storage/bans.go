@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Ban rules
+
+	Customers missing too many sessions are banned from booking for a while,
+	so that their spots go to other members.
+*/
+
+// WithNoShowPenalty bans the customers with more than `limit` no-shows
+// within `window` from booking classes, for the duration of `ban`. No
+// customer is banned by default.
+func WithNoShowPenalty(limit int, window, ban time.Duration) Option {
+	return func(o *options) {
+		o.noShowLimit = limit
+		o.noShowWindow = window
+		o.banDuration = ban
+	}
+}
+
+// checkBanned fails with a BannedError when the customer has an active ban
+func checkBanned(tx Tx, clock Clock, customer string) error {
+	bans, err := tx.GetBans()
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	for _, ban := range bans {
+		if ban.Customer == customer && ban.Active(now) {
+			return &BannedError{Ban: ban}
+		}
+	}
+
+	return nil
+}
+
+// banNoShows bans the given customers when they have too many no-shows and
+// no active ban already
+func banNoShows(tx Tx, o *options, customers []string) error {
+	if o.noShowWindow <= 0 || o.banDuration <= 0 {
+		return nil
+	}
+	bookings, err := tx.GetBookings()
+	if err != nil {
+		return err
+	}
+
+	now := o.clock.Now()
+	since := now.Add(-o.noShowWindow)
+	for _, customer := range customers {
+		count := 0
+		for _, b := range bookings {
+			if b.Customer == customer && b.Status == models.BookingNoShow && !b.Date.Before(since) {
+				count++
+			}
+		}
+		if count <= o.noShowLimit {
+			continue
+		}
+
+		err := checkBanned(tx, o.clock, customer)
+		if _, banned := err.(*BannedError); banned {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.AddBan(&models.Ban{
+			Customer:  customer,
+			Reason:    fmt.Sprintf("%d no-shows since %s", count, since.Format("2006-01-02")),
+			CreatedAt: now,
+			ExpiresAt: now.Add(o.banDuration),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// liftBan implements Tx.LiftBan on top of the other operations, only active
+// bans can be lifted
+func liftBan(tx Tx, clock Clock, ID int, by string) (*models.Ban, error) {
+	ban, err := tx.GetBan(ID)
+	if err != nil {
+		return nil, err
+	}
+	now := clock.Now()
+	if !ban.Active(now) {
+		return nil, fmt.Errorf("ban %d is not active: %w", ID, ErrConflict)
+	}
+
+	ban.LiftedBy, ban.LiftedAt = by, &now
+	if err := tx.UpdateBan(ID, ban); err != nil {
+		return nil, err
+	}
+
+	return ban, nil
+}
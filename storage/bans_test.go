@@ -0,0 +1,65 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+	"github.com/masci/go-rest-playground/storage/storagetest"
+)
+
+func TestNoShowPenalty(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, 2, d, 0, 0, 0, 0, time.UTC) }
+	clock := storagetest.NewFakeClock(day(10))
+	s := storage.NewVolatileStorage(
+		storage.WithSeed(storage.DefaultSeed()),
+		storage.WithClock(clock),
+		storage.WithNoShowPenalty(3, 7*24*time.Hour, 14*24*time.Hour),
+	)
+	defer s.Close()
+	book := func(customer string, date time.Time) error {
+		_, err := s.AddBooking(&models.Booking{Customer: customer, Class: "PI0001", Date: date})
+		return err
+	}
+
+	// the first no-show is out of the window, three are still fine
+	for _, date := range []time.Time{day(1), day(5), day(6), day(7)} {
+		if err := book("Foo", date); err != nil {
+			t.Fatalf("got %s", err)
+		}
+	}
+	if marked, err := s.MarkNoShows(); err != nil || len(marked) != 4 {
+		t.Fatalf("got %d marked, %v", len(marked), err)
+	}
+	if bans, _ := s.GetBans(); len(bans) != 0 {
+		t.Fatalf("got %+v, want no bans", bans)
+	}
+
+	// one more is too many
+	if err := book("Foo", day(8)); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	clock.Set(day(12))
+	if _, err := s.MarkNoShows(); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	bans, _ := s.GetBans()
+	if len(bans) != 1 || bans[0].Customer != "Foo" || !bans[0].ExpiresAt.Equal(day(26)) {
+		t.Fatalf("got %+v, want a ban for Foo until %s", bans, day(26))
+	}
+	banned := &storage.BannedError{}
+	if err := book("Foo", day(20)); !errors.As(err, &banned) || !banned.Ban.ExpiresAt.Equal(day(26)) {
+		t.Errorf("got %v, want a BannedError", err)
+	}
+	if err := book("Bar", day(20)); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// bans expire
+	clock.Set(day(26))
+	if err := book("Foo", day(27)); err != nil {
+		t.Errorf("got %s", err)
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Blackout rules
+
+	Blackouts close the studio for some days, everywhere or at a location or
+	for a class only. Days are the ones of the time zone of each class.
+*/
+
+// checkBlackout validates the blackout `b` before it's saved
+func checkBlackout(tx Tx, b *models.Blackout) error {
+	if b.EndDate.Before(b.StartDate) {
+		return errors.New("the blackout can't end before it starts")
+	}
+	if b.Location != 0 && b.Class != "" {
+		return errors.New("the blackout closes either a location or a class, not both")
+	}
+	if b.Location != 0 {
+		if _, err := tx.GetLocation(b.Location); err != nil {
+			return err
+		}
+	}
+	if b.Class != "" {
+		if _, err := tx.GetClass(b.Class); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BlackedOut returns the blackout closing the class `c` at `t`, or nil when
+// the class is open
+func BlackedOut(tx Tx, c *models.Class, t time.Time) (*models.Blackout, error) {
+	blackouts, err := tx.GetBlackouts()
+	if err != nil || len(blackouts) == 0 {
+		return nil, err
+	}
+	closed, err := ClosedBy(tx, c, blackouts)
+	if err != nil {
+		return nil, err
+	}
+	return closed(t), nil
+}
+
+// ClosedBy returns a function telling which of the `blackouts` closes the
+// class `c` at a given time, nil when none does. The site of the class is
+// looked up once, so that many sessions can be checked in memory.
+func ClosedBy(tx Tx, c *models.Class, blackouts []*models.Blackout) (func(t time.Time) *models.Blackout, error) {
+	location, loc, err := classSite(tx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(t time.Time) *models.Blackout {
+		for _, b := range blackouts {
+			if covers(b, c, location, loc, t) {
+				return b
+			}
+		}
+		return nil
+	}, nil
+}
+
+// classSite returns the location of the room of the class, zero when it has
+// none, and its time zone
+func classSite(tx Tx, c *models.Class) (int, *time.Location, error) {
+	loc, err := ClassLocation(tx, c)
+	if err != nil || c.Room == 0 {
+		return 0, loc, err
+	}
+	r, err := tx.GetRoom(c.Room)
+	if err != nil {
+		return 0, nil, err
+	}
+	return r.Location, loc, nil
+}
+
+// covers tells wether the blackout closes the class `c`, taking place at
+// `location` in the time zone `loc`, at the time `t`
+func covers(b *models.Blackout, c *models.Class, location int, loc *time.Location, t time.Time) bool {
+	if b.Class != "" && b.Class != c.ID || b.Location != 0 && b.Location != location {
+		return false
+	}
+	return withinDays(t, b.StartDate, b.EndDate, loc)
+}
+
+// checkBlackouts fails with ErrConflict when the booking falls within a
+// blackout of its class
+func checkBlackouts(tx Tx, b *models.Booking, class *models.Class) error {
+	blackout, err := BlackedOut(tx, class, b.Date)
+	if err != nil || blackout == nil {
+		return err
+	}
+
+	return fmt.Errorf("class %s is closed from %s to %s (%s): %w", class.Name, blackout.StartDate.UTC().Format("2006-01-02"),
+		blackout.EndDate.UTC().Format("2006-01-02"), blackoutReason(blackout), ErrConflict)
+}
+
+// cancelBlackedOut cancels the confirmed bookings falling within the
+// blackout and returns them. Members aren't charged as the studio is
+// closing.
+func cancelBlackedOut(tx Tx, clock Clock, blackout *models.Blackout) ([]*models.Booking, error) {
+	bookings, err := tx.GetBookings()
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	cancelled := []*models.Booking{}
+	for _, b := range bookings {
+		if b.Status != models.BookingConfirmed {
+			continue
+		}
+		class, err := tx.GetClass(b.Class)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		location, loc, err := classSite(tx, class)
+		if err != nil {
+			return nil, err
+		}
+		if !covers(blackout, class, location, loc, b.Date) {
+			continue
+		}
+
+		b.Status = models.BookingCancelled
+		b.CancelledBy, b.CancelledAt, b.CancelReason = "", &now, blackoutReason(blackout)
+		b.Cancellation, b.CancelFee, b.CreditForfeited = models.CancelFree, 0, false
+		if err := tx.UpdateBooking(b.ID, b); err != nil {
+			return nil, err
+		}
+		cancelled = append(cancelled, b)
+	}
+
+	return cancelled, nil
+}
+
+func blackoutReason(b *models.Blackout) string {
+	if b.Reason == "" {
+		return "studio closed"
+	}
+	return b.Reason
+}
@@ -0,0 +1,1087 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	classBucket      = []byte("class")
+	classOrderBucket = []byte("class_order") // sequence number -> class ID
+	bookingBucket    = []byte("booking")
+	banBucket        = []byte("ban")
+	instructorBucket = []byte("instructor")
+	locationBucket   = []byte("location")
+	roomBucket       = []byte("room")
+	blackoutBucket   = []byte("blackout")
+
+	// buckets holds the buckets of each tenant
+	buckets = [][]byte{classBucket, classOrderBucket, bookingBucket, banBucket, instructorBucket, locationBucket, roomBucket, blackoutBucket}
+	// tenantBucket nests the buckets of the tenants, but the default one
+	tenantBucket = []byte("tenant")
+)
+
+// BoltStorage implements the Storage interface saving data in a bbolt
+// key-value database on disk. Being written in pure Go, it doesn't need cgo
+// and is a good fit for single-binary deployments.
+//
+// Records are saved as JSON documents, classes keyed by their ID and the
+// other records by their ID encoded as a big-endian integer, so that bolt
+// keeps them sorted.
+//
+// The buckets of the default tenant sit at the top level, the ones of the
+// other tenants are nested in a bucket named after the tenant.
+type BoltStorage struct {
+	*options
+	db     *bolt.DB
+	tenant string
+	shared bool // views share the database of their storage
+}
+
+// boltTx implements the Tx interface within a bolt transaction
+type boltTx struct {
+	*options
+	tx     *bolt.Tx
+	tenant string
+}
+
+// NewBoltStorage opens the database file at `path`, creating it unless it
+// already exists, and loads the seed if any. It panics when the file can't
+// be opened, e.g. because it's locked by another process.
+func NewBoltStorage(path string, opts ...Option) Storage {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		panic(err)
+	}
+
+	s := &BoltStorage{
+		options: newOptions(opts),
+		db:      db,
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range append(buckets, tenantBucket) {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range s.seed.Classes {
+			if tx.Bucket(classBucket).Get([]byte(item.ID)) == nil {
+				if err := boltPutClass(&boltTx{options: s.options, tx: tx}, item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// ForTenant returns a view sharing the database, the buckets of the tenant
+// are created by its first write
+func (s *BoltStorage) ForTenant(ID string) Storage {
+	return &BoltStorage{options: s.options, db: s.db, tenant: ID, shared: true}
+}
+
+func (s *BoltStorage) AddTenant(ID string) error {
+	return s.ForTenant(ID).(*BoltStorage).update(func(*boltTx) error {
+		return nil
+	})
+}
+
+func (s *BoltStorage) HasTenant(ID string) (known bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		known = (&boltTx{tx: tx, tenant: ID}).provisioned()
+		return nil
+	})
+	return known, err
+}
+
+func (s *BoltStorage) Tenants() ([]string, error) {
+	tenants := []string{""}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tenantBucket).ForEach(func(ID, _ []byte) error {
+			tenants = append(tenants, string(ID))
+			return nil
+		})
+	})
+	return tenants, err
+}
+
+// WithTx runs `fn` within a read-write bolt transaction, which is rolled
+// back if the context is done before committing
+func (s *BoltStorage) WithTx(ctx context.Context, fn func(Tx) error) error {
+	return s.update(func(tx *boltTx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// errUnprovisioned rolls back the transactions reading a tenant that has no
+// buckets yet
+var errUnprovisioned = errors.New("tenant not provisioned")
+
+// view runs `fn` within a read-only transaction. A tenant that was never
+// written to has no buckets: `fn` then reads empty ones, created within a
+// read-write transaction which is rolled back.
+func (s *BoltStorage) view(fn func(tx *boltTx) error) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		t := &boltTx{options: s.options, tx: tx, tenant: s.tenant}
+		if !t.provisioned() {
+			return errUnprovisioned
+		}
+		return fn(t)
+	})
+	if err != errUnprovisioned {
+		return err
+	}
+
+	err = s.update(func(tx *boltTx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return errUnprovisioned
+	})
+	if err == errUnprovisioned {
+		return nil
+	}
+	return err
+}
+
+// update runs `fn` within a read-write transaction, creating the buckets of
+// the tenant the first time
+func (s *BoltStorage) update(fn func(tx *boltTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		t := &boltTx{options: s.options, tx: tx, tenant: s.tenant}
+		if err := t.provision(); err != nil {
+			return err
+		}
+		return fn(t)
+	})
+}
+
+// provisioned tells whether the buckets of the tenant exist
+func (t *boltTx) provisioned() bool {
+	return t.tenant == "" || t.tx.Bucket(tenantBucket).Bucket([]byte(t.tenant)) != nil
+}
+
+// provision creates the buckets of the tenant, if needed
+func (t *boltTx) provision() error {
+	if t.provisioned() {
+		return nil
+	}
+	tenant, err := t.tx.Bucket(tenantBucket).CreateBucket([]byte(t.tenant))
+	if err != nil {
+		return err
+	}
+	for _, name := range buckets {
+		if _, err := tenant.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucket returns the bucket `name` of the tenant
+func (t *boltTx) bucket(name []byte) *bolt.Bucket {
+	if t.tenant == "" {
+		return t.tx.Bucket(name)
+	}
+	return t.tx.Bucket(tenantBucket).Bucket([]byte(t.tenant)).Bucket(name)
+}
+
+/*
+	Class management functions
+*/
+
+func (s *BoltStorage) AddClass(c *models.Class) (ID string, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ID, err = tx.AddClass(c)
+		return err
+	})
+	return ID, err
+}
+
+func (s *BoltStorage) GetClasses() (classes []*models.Class, err error) {
+	err = s.view(func(tx *boltTx) error {
+		classes, err = tx.GetClasses()
+		return err
+	})
+	return classes, err
+}
+
+func (s *BoltStorage) GetClass(ID string) (c *models.Class, err error) {
+	err = s.view(func(tx *boltTx) error {
+		c, err = tx.GetClass(ID)
+		return err
+	})
+	return c, err
+}
+
+func (s *BoltStorage) UpdateClass(ID string, c *models.Class) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.UpdateClass(ID, c)
+	})
+}
+
+func (s *BoltStorage) DeleteClass(ID string) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.DeleteClass(ID)
+	})
+}
+
+func (t *boltTx) AddClass(c *models.Class) (string, error) {
+	if err := checkClass(t, c, ""); err != nil {
+		return "", err
+	}
+	ID, err := newClassID(t.ids, c.Name, func(ID string) (bool, error) {
+		return t.bucket(classBucket).Get([]byte(ID)) != nil, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.ID = ID
+	if err := boltPutClass(t, c); err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+func (t *boltTx) GetClasses() ([]*models.Class, error) {
+	retVal := []*models.Class{}
+
+	classes := t.bucket(classBucket)
+	err := t.bucket(classOrderBucket).ForEach(func(_, ID []byte) error {
+		c := &models.Class{}
+		if err := json.Unmarshal(classes.Get(ID), c); err != nil {
+			return err
+		}
+		retVal = append(retVal, c)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetClass(ID string) (*models.Class, error) {
+	c := &models.Class{}
+	if err := boltGetClass(t, ID, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (t *boltTx) UpdateClass(ID string, c *models.Class) error {
+	classes := t.bucket(classBucket)
+	if classes.Get([]byte(ID)) == nil {
+		return notFound("Class", ID)
+	}
+	if err := checkClass(t, c, ID); err != nil {
+		return err
+	}
+
+	c.ID = ID
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return classes.Put([]byte(ID), data)
+}
+
+func (t *boltTx) DeleteClass(ID string) error {
+	classes := t.bucket(classBucket)
+	if classes.Get([]byte(ID)) == nil {
+		return notFound("Class", ID)
+	}
+	if err := classes.Delete([]byte(ID)); err != nil {
+		return err
+	}
+
+	// deleting from a bucket while iterating with ForEach isn't safe
+	cur := t.bucket(classOrderBucket).Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if string(v) == ID {
+			return cur.Delete()
+		}
+	}
+	return nil
+}
+
+// boltGetClass loads the class `ID` into `c`
+func boltGetClass(t *boltTx, ID string, c *models.Class) error {
+	data := t.bucket(classBucket).Get([]byte(ID))
+	if data == nil {
+		return notFound("Class", ID)
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+// boltPutClass saves a new class, keeping track of the insertion order
+func boltPutClass(t *boltTx, c *models.Class) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := t.bucket(classBucket).Put([]byte(c.ID), data); err != nil {
+		return err
+	}
+
+	order := t.bucket(classOrderBucket)
+	seq, err := order.NextSequence()
+	if err != nil {
+		return err
+	}
+	return order.Put(itob(seq), []byte(c.ID))
+}
+
+/*
+	Booking management functions
+*/
+
+func (s *BoltStorage) AddBooking(b *models.Booking) (ID int, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ID, err = tx.AddBooking(b)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return ID, nil
+}
+
+func (s *BoltStorage) GetBookings() (bookings []*models.Booking, err error) {
+	err = s.view(func(tx *boltTx) error {
+		bookings, err = tx.GetBookings()
+		return err
+	})
+	return bookings, err
+}
+
+func (s *BoltStorage) GetBooking(ID int) (b *models.Booking, err error) {
+	err = s.view(func(tx *boltTx) error {
+		b, err = tx.GetBooking(ID)
+		return err
+	})
+	return b, err
+}
+
+func (s *BoltStorage) UpdateBooking(ID int, booking *models.Booking) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.UpdateBooking(ID, booking)
+	})
+}
+
+func (s *BoltStorage) DeleteBooking(ID int) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.DeleteBooking(ID)
+	})
+}
+
+func (s *BoltStorage) CancelBooking(ID int, by, reason string) (b *models.Booking, err error) {
+	err = s.update(func(tx *boltTx) error {
+		b, err = tx.CancelBooking(ID, by, reason)
+		return err
+	})
+	return b, err
+}
+
+func (s *BoltStorage) CheckIn(ID int) (b *models.Booking, err error) {
+	err = s.update(func(tx *boltTx) error {
+		b, err = tx.CheckIn(ID)
+		return err
+	})
+	return b, err
+}
+
+func (s *BoltStorage) MarkNoShows() (marked []*models.Booking, err error) {
+	err = s.update(func(tx *boltTx) error {
+		marked, err = tx.MarkNoShows()
+		return err
+	})
+	return marked, err
+}
+
+func (t *boltTx) AddBooking(b *models.Booking) (int, error) {
+	// check wether the booking is valid
+	class := &models.Class{}
+	if err := boltGetClass(t, b.Class, class); err != nil {
+		return -1, err
+	}
+	class, err := roomCapacity(t, class)
+	if err != nil {
+		return -1, err
+	}
+	if err := checkBanned(t, t.clock, b.Customer); err != nil {
+		return -1, err
+	}
+	taken, err := t.GetBookings()
+	if err != nil {
+		return -1, err
+	}
+	if err := checkBooking(t, t.clock, b, class, taken); err != nil {
+		return -1, err
+	}
+
+	// sequences never go back, so deleted ids aren't reused
+	seq, err := t.bucket(bookingBucket).NextSequence()
+	if err != nil {
+		return -1, err
+	}
+	b.ID = int(seq)
+	if err := boltPutBooking(t, b); err != nil {
+		return -1, err
+	}
+	return b.ID, nil
+}
+
+func (t *boltTx) GetBookings() ([]*models.Booking, error) {
+	retVal := []*models.Booking{}
+
+	err := t.bucket(bookingBucket).ForEach(func(_, data []byte) error {
+		b := &models.Booking{}
+		if err := json.Unmarshal(data, b); err != nil {
+			return err
+		}
+		upgradeBooking(b)
+		retVal = append(retVal, b)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetBooking(ID int) (*models.Booking, error) {
+	data := t.bucket(bookingBucket).Get(intKey(ID))
+	if data == nil {
+		return nil, notFound("Booking", ID)
+	}
+
+	b := &models.Booking{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	upgradeBooking(b)
+	return b, nil
+}
+
+func (t *boltTx) UpdateBooking(ID int, booking *models.Booking) error {
+	old, err := t.GetBooking(ID)
+	if err != nil {
+		return err
+	}
+	if err := checkChange(t, t.clock, old, booking); err != nil {
+		return err
+	}
+
+	booking.ID = ID
+	return boltPutBooking(t, booking)
+}
+
+func (t *boltTx) DeleteBooking(ID int) error {
+	bookings := t.bucket(bookingBucket)
+	if bookings.Get(intKey(ID)) == nil {
+		return notFound("Booking", ID)
+	}
+
+	return bookings.Delete(intKey(ID))
+}
+
+func (t *boltTx) CancelBooking(ID int, by, reason string) (*models.Booking, error) {
+	return cancelBooking(t, t.clock, ID, by, reason)
+}
+
+func (t *boltTx) CheckIn(ID int) (*models.Booking, error) {
+	return checkIn(t, t.options, ID)
+}
+
+func (t *boltTx) MarkNoShows() ([]*models.Booking, error) {
+	return markNoShows(t, t.options)
+}
+
+func boltPutBooking(t *boltTx, b *models.Booking) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	return t.bucket(bookingBucket).Put(intKey(b.ID), data)
+}
+
+/*
+	Ban management functions
+*/
+
+func (s *BoltStorage) AddBan(ban *models.Ban) (ID int, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ID, err = tx.AddBan(ban)
+		return err
+	})
+	return ID, err
+}
+
+func (s *BoltStorage) GetBans() (bans []*models.Ban, err error) {
+	err = s.view(func(tx *boltTx) error {
+		bans, err = tx.GetBans()
+		return err
+	})
+	return bans, err
+}
+
+func (s *BoltStorage) GetBan(ID int) (ban *models.Ban, err error) {
+	err = s.view(func(tx *boltTx) error {
+		ban, err = tx.GetBan(ID)
+		return err
+	})
+	return ban, err
+}
+
+func (s *BoltStorage) UpdateBan(ID int, ban *models.Ban) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.UpdateBan(ID, ban)
+	})
+}
+
+func (s *BoltStorage) LiftBan(ID int, by string) (ban *models.Ban, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ban, err = tx.LiftBan(ID, by)
+		return err
+	})
+	return ban, err
+}
+
+func (t *boltTx) AddBan(ban *models.Ban) (int, error) {
+	seq, err := t.bucket(banBucket).NextSequence()
+	if err != nil {
+		return -1, err
+	}
+	ban.ID = int(seq)
+	if err := boltPutBan(t, ban); err != nil {
+		return -1, err
+	}
+	return ban.ID, nil
+}
+
+func (t *boltTx) GetBans() ([]*models.Ban, error) {
+	retVal := []*models.Ban{}
+
+	err := t.bucket(banBucket).ForEach(func(_, data []byte) error {
+		ban := &models.Ban{}
+		if err := json.Unmarshal(data, ban); err != nil {
+			return err
+		}
+		retVal = append(retVal, ban)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetBan(ID int) (*models.Ban, error) {
+	data := t.bucket(banBucket).Get(intKey(ID))
+	if data == nil {
+		return nil, notFound("Ban", ID)
+	}
+
+	ban := &models.Ban{}
+	if err := json.Unmarshal(data, ban); err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+func (t *boltTx) UpdateBan(ID int, ban *models.Ban) error {
+	if t.bucket(banBucket).Get(intKey(ID)) == nil {
+		return notFound("Ban", ID)
+	}
+
+	ban.ID = ID
+	return boltPutBan(t, ban)
+}
+
+func (t *boltTx) LiftBan(ID int, by string) (*models.Ban, error) {
+	return liftBan(t, t.clock, ID, by)
+}
+
+func boltPutBan(t *boltTx, ban *models.Ban) error {
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return err
+	}
+
+	return t.bucket(banBucket).Put(intKey(ban.ID), data)
+}
+
+/*
+	Instructor management functions
+*/
+
+func (s *BoltStorage) AddInstructor(i *models.Instructor) (ID int, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ID, err = tx.AddInstructor(i)
+		return err
+	})
+	return ID, err
+}
+
+func (s *BoltStorage) GetInstructors() (instructors []*models.Instructor, err error) {
+	err = s.view(func(tx *boltTx) error {
+		instructors, err = tx.GetInstructors()
+		return err
+	})
+	return instructors, err
+}
+
+func (s *BoltStorage) GetInstructor(ID int) (i *models.Instructor, err error) {
+	err = s.view(func(tx *boltTx) error {
+		i, err = tx.GetInstructor(ID)
+		return err
+	})
+	return i, err
+}
+
+func (s *BoltStorage) UpdateInstructor(ID int, i *models.Instructor) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.UpdateInstructor(ID, i)
+	})
+}
+
+func (s *BoltStorage) DeleteInstructor(ID int) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.DeleteInstructor(ID)
+	})
+}
+
+func (t *boltTx) AddInstructor(i *models.Instructor) (int, error) {
+	seq, err := t.bucket(instructorBucket).NextSequence()
+	if err != nil {
+		return -1, err
+	}
+	i.ID = int(seq)
+	if err := boltPutInstructor(t, i); err != nil {
+		return -1, err
+	}
+	return i.ID, nil
+}
+
+func (t *boltTx) GetInstructors() ([]*models.Instructor, error) {
+	retVal := []*models.Instructor{}
+
+	err := t.bucket(instructorBucket).ForEach(func(_, data []byte) error {
+		i := &models.Instructor{}
+		if err := json.Unmarshal(data, i); err != nil {
+			return err
+		}
+		retVal = append(retVal, i)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetInstructor(ID int) (*models.Instructor, error) {
+	data := t.bucket(instructorBucket).Get(intKey(ID))
+	if data == nil {
+		return nil, notFound("Instructor", ID)
+	}
+
+	i := &models.Instructor{}
+	if err := json.Unmarshal(data, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (t *boltTx) UpdateInstructor(ID int, i *models.Instructor) error {
+	if t.bucket(instructorBucket).Get(intKey(ID)) == nil {
+		return notFound("Instructor", ID)
+	}
+
+	i.ID = ID
+	return boltPutInstructor(t, i)
+}
+
+func (t *boltTx) DeleteInstructor(ID int) error {
+	instructors := t.bucket(instructorBucket)
+	if instructors.Get(intKey(ID)) == nil {
+		return notFound("Instructor", ID)
+	}
+	if err := checkUnassigned(t, ID); err != nil {
+		return err
+	}
+
+	return instructors.Delete(intKey(ID))
+}
+
+func boltPutInstructor(t *boltTx, i *models.Instructor) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	return t.bucket(instructorBucket).Put(intKey(i.ID), data)
+}
+
+/*
+	Location management functions
+*/
+
+func (s *BoltStorage) AddLocation(l *models.Location) (ID int, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ID, err = tx.AddLocation(l)
+		return err
+	})
+	return ID, err
+}
+
+func (s *BoltStorage) GetLocations() (locations []*models.Location, err error) {
+	err = s.view(func(tx *boltTx) error {
+		locations, err = tx.GetLocations()
+		return err
+	})
+	return locations, err
+}
+
+func (s *BoltStorage) GetLocation(ID int) (l *models.Location, err error) {
+	err = s.view(func(tx *boltTx) error {
+		l, err = tx.GetLocation(ID)
+		return err
+	})
+	return l, err
+}
+
+func (s *BoltStorage) UpdateLocation(ID int, l *models.Location) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.UpdateLocation(ID, l)
+	})
+}
+
+func (s *BoltStorage) DeleteLocation(ID int) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.DeleteLocation(ID)
+	})
+}
+
+func (t *boltTx) AddLocation(l *models.Location) (int, error) {
+	if err := checkTimeZone(l.TimeZone); err != nil {
+		return -1, err
+	}
+	seq, err := t.bucket(locationBucket).NextSequence()
+	if err != nil {
+		return -1, err
+	}
+	l.ID = int(seq)
+	if err := boltPutLocation(t, l); err != nil {
+		return -1, err
+	}
+	return l.ID, nil
+}
+
+func (t *boltTx) GetLocations() ([]*models.Location, error) {
+	retVal := []*models.Location{}
+
+	err := t.bucket(locationBucket).ForEach(func(_, data []byte) error {
+		l := &models.Location{}
+		if err := json.Unmarshal(data, l); err != nil {
+			return err
+		}
+		retVal = append(retVal, l)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetLocation(ID int) (*models.Location, error) {
+	data := t.bucket(locationBucket).Get(intKey(ID))
+	if data == nil {
+		return nil, notFound("Location", ID)
+	}
+
+	l := &models.Location{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (t *boltTx) UpdateLocation(ID int, l *models.Location) error {
+	if t.bucket(locationBucket).Get(intKey(ID)) == nil {
+		return notFound("Location", ID)
+	}
+	if err := checkTimeZone(l.TimeZone); err != nil {
+		return err
+	}
+
+	l.ID = ID
+	return boltPutLocation(t, l)
+}
+
+func (t *boltTx) DeleteLocation(ID int) error {
+	locations := t.bucket(locationBucket)
+	if locations.Get(intKey(ID)) == nil {
+		return notFound("Location", ID)
+	}
+	if err := checkNoRooms(t, ID); err != nil {
+		return err
+	}
+
+	return locations.Delete(intKey(ID))
+}
+
+func boltPutLocation(t *boltTx, l *models.Location) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return t.bucket(locationBucket).Put(intKey(l.ID), data)
+}
+
+/*
+	Room management functions
+*/
+
+func (s *BoltStorage) AddRoom(r *models.Room) (ID int, err error) {
+	err = s.update(func(tx *boltTx) error {
+		ID, err = tx.AddRoom(r)
+		return err
+	})
+	return ID, err
+}
+
+func (s *BoltStorage) GetRooms() (rooms []*models.Room, err error) {
+	err = s.view(func(tx *boltTx) error {
+		rooms, err = tx.GetRooms()
+		return err
+	})
+	return rooms, err
+}
+
+func (s *BoltStorage) GetRoom(ID int) (r *models.Room, err error) {
+	err = s.view(func(tx *boltTx) error {
+		r, err = tx.GetRoom(ID)
+		return err
+	})
+	return r, err
+}
+
+func (s *BoltStorage) UpdateRoom(ID int, r *models.Room) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.UpdateRoom(ID, r)
+	})
+}
+
+func (s *BoltStorage) DeleteRoom(ID int) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.DeleteRoom(ID)
+	})
+}
+
+func (t *boltTx) AddRoom(r *models.Room) (int, error) {
+	if err := checkLocation(t, r); err != nil {
+		return -1, err
+	}
+	seq, err := t.bucket(roomBucket).NextSequence()
+	if err != nil {
+		return -1, err
+	}
+	r.ID = int(seq)
+	if err := boltPutRoom(t, r); err != nil {
+		return -1, err
+	}
+	return r.ID, nil
+}
+
+func (t *boltTx) GetRooms() ([]*models.Room, error) {
+	retVal := []*models.Room{}
+
+	err := t.bucket(roomBucket).ForEach(func(_, data []byte) error {
+		r := &models.Room{}
+		if err := json.Unmarshal(data, r); err != nil {
+			return err
+		}
+		retVal = append(retVal, r)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetRoom(ID int) (*models.Room, error) {
+	data := t.bucket(roomBucket).Get(intKey(ID))
+	if data == nil {
+		return nil, notFound("Room", ID)
+	}
+
+	r := &models.Room{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (t *boltTx) UpdateRoom(ID int, r *models.Room) error {
+	if t.bucket(roomBucket).Get(intKey(ID)) == nil {
+		return notFound("Room", ID)
+	}
+	if err := checkLocation(t, r); err != nil {
+		return err
+	}
+
+	r.ID = ID
+	return boltPutRoom(t, r)
+}
+
+func (t *boltTx) DeleteRoom(ID int) error {
+	rooms := t.bucket(roomBucket)
+	if rooms.Get(intKey(ID)) == nil {
+		return notFound("Room", ID)
+	}
+	if err := checkRoomUnused(t, ID); err != nil {
+		return err
+	}
+
+	return rooms.Delete(intKey(ID))
+}
+
+func boltPutRoom(t *boltTx, r *models.Room) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return t.bucket(roomBucket).Put(intKey(r.ID), data)
+}
+
+/*
+	Blackout management functions
+*/
+
+func (s *BoltStorage) AddBlackout(b *models.Blackout) (cancelled []*models.Booking, err error) {
+	err = s.update(func(tx *boltTx) error {
+		cancelled, err = tx.AddBlackout(b)
+		return err
+	})
+	return cancelled, err
+}
+
+func (s *BoltStorage) GetBlackouts() (blackouts []*models.Blackout, err error) {
+	err = s.view(func(tx *boltTx) error {
+		blackouts, err = tx.GetBlackouts()
+		return err
+	})
+	return blackouts, err
+}
+
+func (s *BoltStorage) GetBlackout(ID int) (b *models.Blackout, err error) {
+	err = s.view(func(tx *boltTx) error {
+		b, err = tx.GetBlackout(ID)
+		return err
+	})
+	return b, err
+}
+
+func (s *BoltStorage) DeleteBlackout(ID int) error {
+	return s.update(func(tx *boltTx) error {
+		return tx.DeleteBlackout(ID)
+	})
+}
+
+func (t *boltTx) AddBlackout(b *models.Blackout) ([]*models.Booking, error) {
+	if err := checkBlackout(t, b); err != nil {
+		return nil, err
+	}
+	seq, err := t.bucket(blackoutBucket).NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	b.ID = int(seq)
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.bucket(blackoutBucket).Put(intKey(b.ID), data); err != nil {
+		return nil, err
+	}
+
+	return cancelBlackedOut(t, t.clock, b)
+}
+
+func (t *boltTx) GetBlackouts() ([]*models.Blackout, error) {
+	retVal := []*models.Blackout{}
+
+	err := t.bucket(blackoutBucket).ForEach(func(_, data []byte) error {
+		b := &models.Blackout{}
+		if err := json.Unmarshal(data, b); err != nil {
+			return err
+		}
+		retVal = append(retVal, b)
+		return nil
+	})
+
+	return retVal, err
+}
+
+func (t *boltTx) GetBlackout(ID int) (*models.Blackout, error) {
+	data := t.bucket(blackoutBucket).Get(intKey(ID))
+	if data == nil {
+		return nil, notFound("Blackout", ID)
+	}
+
+	b := &models.Blackout{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t *boltTx) DeleteBlackout(ID int) error {
+	blackouts := t.bucket(blackoutBucket)
+	if blackouts.Get(intKey(ID)) == nil {
+		return notFound("Blackout", ID)
+	}
+
+	return blackouts.Delete(intKey(ID))
+}
+
+/*
+	Others
+*/
+
+// intKey encodes the identifiers of everything but classes, negative ones can't exist
+func intKey(ID int) []byte {
+	if ID < 0 {
+		return itob(0)
+	}
+	return itob(uint64(ID))
+}
+
+// itob encodes integers as big-endian so that keys sort numerically
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func (s *BoltStorage) Close() error {
+	if s.shared {
+		return nil
+	}
+	return s.db.Close()
+}
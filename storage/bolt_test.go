@@ -0,0 +1,47 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+func TestBoltStorageReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gym.db")
+
+	s := storage.NewBoltStorage(path, storage.WithSeed(storage.DefaultSeed()))
+	c := &models.Class{Name: "Crossfit"}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	b := &models.Booking{Class: "FB0001", Customer: "Jane Doe", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)}
+	if _, err := s.AddBooking(b); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if err := s.DeleteBooking(b.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// data is still there, and the seed doesn't duplicate the fixtures
+	s = storage.NewBoltStorage(path, storage.WithSeed(storage.DefaultSeed()))
+	defer s.Close()
+	classes, _ := s.GetClasses()
+	if len(classes) != 5 || classes[4].ID != c.ID {
+		t.Errorf("got %d classes, want %d ending with %s", len(classes), 5, c.ID)
+	}
+
+	// identifiers of deleted bookings aren't reused
+	ID, err := s.AddBooking(&models.Booking{Class: "FB0001", Customer: "John Doe", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if ID != b.ID+1 {
+		t.Errorf("got %d, want %d", ID, b.ID+1)
+	}
+}
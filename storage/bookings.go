@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Booking rules
+
+	The logic shared by the storages when creating and changing bookings.
+*/
+
+// checkBooking validates a new booking against its class and the bookings
+// of the class already taken, then sets the fields the storage is in charge of
+func checkBooking(tx Tx, clock Clock, b *models.Booking, class *models.Class, taken []*models.Booking) error {
+	if err := checkRules(tx, clock, b, class, taken); err != nil {
+		return err
+	}
+
+	b.Status = models.BookingConfirmed
+	b.CancelledBy, b.CancelledAt, b.CancelReason = "", nil, ""
+	b.Cancellation, b.CancelFee, b.CreditForfeited = "", 0, false
+	b.CheckedInAt = nil
+	return nil
+}
+
+// checkRules tells wether the class can be booked for the customer at the
+// date of the booking `b`, given the bookings already taken
+func checkRules(tx Tx, clock Clock, b *models.Booking, class *models.Class, taken []*models.Booking) error {
+	loc, err := ClassLocation(tx, class)
+	if err != nil {
+		return err
+	}
+	if !canBook(b, class, loc) {
+		return fmt.Errorf("class %s is available from %s to %s (%s), not on %s", class.Name,
+			class.StartDate.UTC().Format("2006-01-02"), class.EndDate.UTC().Format("2006-01-02"), loc, b.Date.In(loc).Format("2006-01-02"))
+	}
+	if err := checkBookingWindow(clock, b, class, loc); err != nil {
+		return err
+	}
+	if err := checkBlackouts(tx, b, class); err != nil {
+		return err
+	}
+	if isFull(class, b, taken, loc) {
+		return fmt.Errorf("class %s is full at %s: %w", class.Name, b.Date.In(loc).Format("2006-01-02"), ErrConflict)
+	}
+	return nil
+}
+
+// checkChange validates the update of the booking stored as `old` to `b`.
+// Moving it to another date or class, or handing it to another customer, is
+// booking anew: only confirmed bookings can be moved and the rules of new
+// bookings apply. Other changes, e.g. to the status, aren't checked.
+func checkChange(tx Tx, clock Clock, old, b *models.Booking) error {
+	if b.Date.Equal(old.Date) && b.Class == old.Class && b.Customer == old.Customer {
+		return nil
+	}
+	if old.Status != models.BookingConfirmed {
+		return fmt.Errorf("booking %d is %s: %w", old.ID, old.Status, ErrConflict)
+	}
+
+	class, err := tx.GetClass(b.Class)
+	if err != nil {
+		return err
+	}
+	if class, err = roomCapacity(tx, class); err != nil {
+		return err
+	}
+	if err := checkBanned(tx, clock, b.Customer); err != nil {
+		return err
+	}
+	bookings, err := tx.GetBookings()
+	if err != nil {
+		return err
+	}
+	taken := []*models.Booking{}
+	for _, other := range bookings {
+		if other.ID != old.ID {
+			taken = append(taken, other)
+		}
+	}
+	return checkRules(tx, clock, b, class, taken)
+}
+
+// checkBookingWindow fails with ErrConflict when booking the session isn't
+// open yet, or not anymore, according to the booking window of the class
+func checkBookingWindow(clock Clock, b *models.Booking, class *models.Class, loc *time.Location) error {
+	now := clock.Now()
+	opens, closes := bookingWindow(class, b.Date, loc)
+	if !opens.IsZero() && now.Before(opens) {
+		return fmt.Errorf("booking class %s on %s opens at %s: %w", class.Name,
+			b.Date.In(loc).Format(time.RFC3339), opens.Format(time.RFC3339), ErrConflict)
+	}
+	if !closes.IsZero() && !now.Before(closes) {
+		return fmt.Errorf("booking class %s on %s closed at %s: %w", class.Name,
+			b.Date.In(loc).Format(time.RFC3339), closes.In(loc).Format(time.RFC3339), ErrConflict)
+	}
+	return nil
+}
+
+// isFull tells wether the class is fully booked on the day of the booking, in
+// the time zone `loc` of the class. Cancelled bookings don't count and a
+// capacity of zero means no limit.
+func isFull(class *models.Class, b *models.Booking, taken []*models.Booking, loc *time.Location) bool {
+	if class.Capacity <= 0 {
+		return false
+	}
+
+	count := 0
+	for _, other := range taken {
+		if other.Class == class.ID && other.Status != models.BookingCancelled && sameDay(other.Date, b.Date, loc) {
+			count++
+		}
+	}
+
+	return count >= class.Capacity
+}
+
+// sameDay tells wether two times fall on the same calendar day in `loc`
+func sameDay(a, b time.Time, loc *time.Location) bool {
+	ay, am, ad := a.In(loc).Date()
+	by, bm, bd := b.In(loc).Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// cancelBooking implements Tx.CancelBooking on top of the other operations,
+// only confirmed bookings can be cancelled
+func cancelBooking(tx Tx, clock Clock, ID int, by, reason string) (*models.Booking, error) {
+	b, err := tx.GetBooking(ID)
+	if err != nil {
+		return nil, err
+	}
+	if b.Status != models.BookingConfirmed {
+		return nil, fmt.Errorf("booking %d is %s: %w", ID, b.Status, ErrConflict)
+	}
+	// bookings of a class that's gone have no policy to honour
+	class, err := tx.GetClass(b.Class)
+	if errors.Is(err, ErrNotFound) {
+		class, err = &models.Class{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	b.Status = models.BookingCancelled
+	b.CancelledBy, b.CancelledAt, b.CancelReason = by, &now, reason
+	b.Cancellation, b.CancelFee, b.CreditForfeited = models.CancelFree, 0, false
+	if isLate(class.CancellationPolicy, b, now) {
+		b.Cancellation = models.CancelLate
+		b.CancelFee, b.CreditForfeited = class.Fee, class.ForfeitCredit
+	}
+	if err := tx.UpdateBooking(ID, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// isLate tells wether cancelling the booking at `now` falls within the cutoff
+// of the policy
+func isLate(policy models.CancellationPolicy, b *models.Booking, now time.Time) bool {
+	if policy.Cutoff <= 0 {
+		return false
+	}
+
+	return !now.Before(b.Date.Add(-time.Duration(policy.Cutoff)))
+}
+
+// checkIn implements Tx.CheckIn on top of the other operations, only
+// confirmed bookings can be checked in
+func checkIn(tx Tx, o *options, ID int) (*models.Booking, error) {
+	b, err := tx.GetBooking(ID)
+	if err != nil {
+		return nil, err
+	}
+	if b.Status != models.BookingConfirmed {
+		return nil, fmt.Errorf("booking %d is %s: %w", ID, b.Status, ErrConflict)
+	}
+
+	now := o.clock.Now()
+	opens, closes := b.Date.Add(-o.checkInBefore), b.Date.Add(o.checkInAfter)
+	if now.Before(opens) || now.After(closes) {
+		return nil, fmt.Errorf("check-in for booking %d is open from %s to %s: %w",
+			ID, opens.Format(time.RFC3339), closes.Format(time.RFC3339), ErrConflict)
+	}
+
+	b.Status = models.BookingAttended
+	b.CheckedInAt = &now
+	if err := tx.UpdateBooking(ID, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// markNoShows implements Tx.MarkNoShows on top of the other operations
+func markNoShows(tx Tx, o *options) ([]*models.Booking, error) {
+	bookings, err := tx.GetBookings()
+	if err != nil {
+		return nil, err
+	}
+
+	now := o.clock.Now()
+	marked := []*models.Booking{}
+	customers := []string{}
+	for _, b := range bookings {
+		if b.Status != models.BookingConfirmed || !now.After(b.Date.Add(o.checkInAfter)) {
+			continue
+		}
+		b.Status = models.BookingNoShow
+		if err := tx.UpdateBooking(b.ID, b); err != nil {
+			return nil, err
+		}
+		marked = append(marked, b)
+		customers = append(customers, b.Customer)
+	}
+
+	if err := banNoShows(tx, o, customers); err != nil {
+		return nil, err
+	}
+	return marked, nil
+}
+
+// upgradeBooking fills in the fields missing in bookings saved by older
+// versions of the storages
+func upgradeBooking(b *models.Booking) {
+	if b.Status == "" {
+		b.Status = models.BookingConfirmed
+	}
+	if b.Status == models.BookingCancelled && b.Cancellation == "" {
+		b.Cancellation = models.CancelFree
+	}
+}
@@ -0,0 +1,51 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+	"github.com/masci/go-rest-playground/storage/storagetest"
+)
+
+func TestBookingWindow(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	clock := storagetest.NewFakeClock(time.Date(2020, 3, 1, 9, 0, 0, 0, rome))
+	s := storage.NewVolatileStorage(storage.WithClock(clock))
+	defer s.Close()
+
+	c := &models.Class{
+		Name:          "Pilates",
+		StartDate:     time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2020, 4, 30, 0, 0, 0, 0, time.UTC),
+		TimeZone:      "Europe/Rome",
+		BookingWindow: models.BookingWindow{OpensDays: 2, ClosesMinutes: 30},
+	}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	// the clocks go forward on 2020-03-29, two days before 7am on the 30th
+	// is 7am on the 28th, 49 hours earlier
+	session := time.Date(2020, 3, 30, 7, 0, 0, 0, rome)
+
+	tests := []struct {
+		now  time.Time
+		want error
+	}{
+		{time.Date(2020, 3, 28, 6, 59, 0, 0, rome), storage.ErrConflict},
+		{time.Date(2020, 3, 28, 7, 0, 0, 0, rome), nil},
+		{time.Date(2020, 3, 30, 6, 29, 0, 0, rome), nil},
+		{time.Date(2020, 3, 30, 6, 30, 0, 0, rome), storage.ErrConflict},
+		{time.Date(2020, 3, 30, 8, 0, 0, 0, rome), storage.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		clock.Set(tt.now)
+		_, err := s.AddBooking(&models.Booking{Customer: "Foo", Class: c.ID, Date: session})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("booking at %s: got %v, want %v", tt.now, err, tt.want)
+		}
+	}
+}
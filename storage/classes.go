@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Class rules
+
+	Classes share their instructor and their room with no other class
+	whose sessions overlap with theirs. Sessions start at the same time of
+	each day of the class, or take up the whole day when the class has no
+	duration.
+*/
+
+// checkClass validates the class `c` before it's saved, `ID` is the
+// identifier of the class when it's being updated
+func checkClass(tx Tx, c *models.Class, ID string) error {
+	if err := checkTimeZone(c.TimeZone); err != nil {
+		return err
+	}
+	if err := checkWindow(c.BookingWindow); err != nil {
+		return err
+	}
+	if err := checkSessionTime(c); err != nil {
+		return err
+	}
+	if err := checkInstructor(tx, c, ID); err != nil {
+		return err
+	}
+	return checkRoom(tx, c, ID)
+}
+
+// checkWindow fails when the booking window of a class can't ever be open
+func checkWindow(w models.BookingWindow) error {
+	if w.OpensDays < 0 || w.ClosesMinutes < 0 {
+		return errors.New("the booking window can't open or close after the class starts")
+	}
+	if w.OpensDays > 0 && w.ClosesMinutes >= w.OpensDays*24*60 {
+		return errors.New("the booking window closes before it opens")
+	}
+	return nil
+}
+
+// checkSessionTime fails when the sessions of a class don't start at a
+// valid time of day, or last less than a day
+func checkSessionTime(c *models.Class) error {
+	if c.StartTime == "" && c.Duration == 0 {
+		return nil
+	}
+	if _, err := time.Parse("15:04", c.StartTime); err != nil {
+		return fmt.Errorf("invalid start time %q, want HH:MM", c.StartTime)
+	}
+	if c.Duration <= 0 || time.Duration(c.Duration) > 24*time.Hour {
+		return errors.New("sessions must last up to a day")
+	}
+	return nil
+}
+
+// session returns when the session of the class taking place on the calendar
+// day `day` starts and ends, in the time zone `loc`
+func session(c *models.Class, day time.Time, loc *time.Location) (start, end time.Time) {
+	y, m, d := day.UTC().Date()
+	if c.Duration == 0 {
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	}
+
+	t, _ := time.Parse("15:04", c.StartTime)
+	start = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	return start, start.Add(time.Duration(c.Duration))
+}
+
+// overlaps tells wether some sessions of two classes overlap, in the time
+// zones they take place in
+func overlaps(tx Tx, a, b *models.Class) (bool, error) {
+	// sessions can run past midnight, or take place in another time zone,
+	// so those of the days next to the ones of `a` count as well
+	first, last := localDay(a.StartDate, time.UTC), localDay(a.EndDate, time.UTC)
+	if localDay(b.StartDate, time.UTC).After(last.AddDate(0, 0, 1)) || localDay(b.EndDate, time.UTC).Before(first.AddDate(0, 0, -1)) {
+		return false, nil
+	}
+	locA, err := ClassLocation(tx, a)
+	if err != nil {
+		return false, err
+	}
+	locB, err := ClassLocation(tx, b)
+	if err != nil {
+		return false, err
+	}
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		start, end := session(a, day, locA)
+		for _, other := range []time.Time{day.AddDate(0, 0, -1), day, day.AddDate(0, 0, 1)} {
+			if !withinDays(other, b.StartDate, b.EndDate, time.UTC) {
+				continue
+			}
+			otherStart, otherEnd := session(b, other, locB)
+			if start.Before(otherEnd) && otherStart.Before(end) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// schedule describes when the sessions of the class take place
+func schedule(c *models.Class) string {
+	days := fmt.Sprintf("from %s to %s", c.StartDate.Format("2006-01-02"), c.EndDate.Format("2006-01-02"))
+	if c.Duration == 0 {
+		return days
+	}
+	return fmt.Sprintf("%s at %s for %s", days, c.StartTime, time.Duration(c.Duration))
+}
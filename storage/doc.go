@@ -5,10 +5,10 @@ The `storage` package implements the data layer for our API.
 The idea behind the design of the package is to make it loosely coupled to the rest of the
 application, in order to ease further development and refactoring as the requirements evolve.
 
-In order to give a better idea of the design, the exercise provides two different ways of
+In order to give a better idea of the design, the exercise provides three different ways of
 storing data that are transparent to the web service logic serving our API. The application
-can store data either in memory, using a simple map that will be lost on exit, or in a SQLite
-database persisted on file.
+can store data either in memory, using a simple map that will be lost on exit, in a SQLite
+database persisted on file, or in a bbolt key-value database file that doesn't require cgo.
 
 ┌───────────────────┐      ┌───────────────────┐
 │                   │      │                   │
@@ -16,12 +16,12 @@ database persisted on file.
 │                   │      │                   │
 └───────────────────┘      └───────────────────┘
                                      ▲
-                       ┌─────────────┴─────────────┐
-                       │                           │
-             ┌───────────────────┐       ┌───────────────────┐
-             │      In-mem       │       │      SQLite       │
-             │  implementation   │       │  implementation   │
-             └───────────────────┘       └───────────────────┘
+                   ┌─────────────────┼─────────────────┐
+                   │                 │                 │
+           ┌───────────────┐ ┌───────────────┐ ┌───────────────┐
+           │    In-mem     │ │    SQLite     │ │     bbolt     │
+           │implementation │ │implementation │ │implementation │
+           └───────────────┘ └───────────────┘ └───────────────┘
 
 */
 package storage
@@ -0,0 +1,25 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package storage
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the name of the database/sql driver used by SqliteStorage,
+// by default the cgo bindings to the SQLite C library
+const sqliteDriver = "sqlite3"
+
+// sqliteDSN returns the data source name of the database file at `path`
+func sqliteDSN(path string) string {
+	return path
+}
+
+// isBusy tells wether the error is SQLITE_BUSY
+func isBusy(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && e.Code == sqlite3.ErrBusy
+}
@@ -0,0 +1,35 @@
+//go:build purego || !cgo
+// +build purego !cgo
+
+package storage
+
+import (
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteDriver is the name of the database/sql driver used by SqliteStorage,
+// building with the `purego` tag, or without cgo, selects a translation of
+// SQLite to Go
+const sqliteDriver = "sqlite"
+
+// sqliteDSN returns the data source name of the database file at `path`.
+// Dates are written in the same format used by the cgo driver, so that
+// database files can be shared by the binaries built either way, and
+// the busy timeout is set to the default of the cgo driver.
+func sqliteDSN(path string) string {
+	params := "_time_format=sqlite&_pragma=busy_timeout(5000)"
+	if strings.Contains(path, "?") {
+		return path + "&" + params
+	}
+	return path + "?" + params
+}
+
+// isBusy tells wether the error is SQLITE_BUSY, extended codes included
+func isBusy(err error) bool {
+	var e *sqlite.Error
+	return errors.As(err, &e) && e.Code()&0xff == sqlite3.SQLITE_BUSY
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+// ErrNotFound is matched by the errors returned when the resource requested
+// doesn't exist, e.g. errors.Is(err, storage.ErrNotFound)
+var ErrNotFound = errors.New("resource not found")
+
+// ErrConflict is matched by the errors returned when the operation isn't
+// allowed by the current state of the data, e.g. cancelling a booking twice
+// or booking a class that's full
+var ErrConflict = errors.New("conflict")
+
+// BannedError is returned when a banned customer tries to book a class, it
+// matches ErrConflict as well
+type BannedError struct {
+	Ban *models.Ban
+}
+
+func (e *BannedError) Error() string {
+	return fmt.Sprintf("customer %s is banned from booking until %s", e.Ban.Customer, e.Ban.ExpiresAt.Format(time.RFC3339))
+}
+
+func (e *BannedError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// notFoundError describes which resource couldn't be found
+type notFoundError struct {
+	resource string
+	ID       interface{}
+}
+
+func notFound(resource string, ID interface{}) error {
+	return &notFoundError{resource: resource, ID: ID}
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("no %s found with id '%v'", e.resource, e.ID)
+}
+
+func (e *notFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Instructor rules
+
+	Classes are taught by one instructor at most, who can't teach two
+	classes whose sessions overlap: a morning and an evening class on the
+	same days are fine, as long as both have a start time and a duration.
+*/
+
+// checkInstructor validates the assignment of the class `c` to its
+// instructor, `ID` is the identifier of the class when it's being updated
+func checkInstructor(tx Tx, c *models.Class, ID string) error {
+	if c.Instructor == 0 {
+		return nil
+	}
+	if _, err := tx.GetInstructor(c.Instructor); err != nil {
+		return err
+	}
+
+	classes, err := tx.GetClasses()
+	if err != nil {
+		return err
+	}
+	for _, other := range classes {
+		if other.ID == ID || other.Instructor != c.Instructor {
+			continue
+		}
+		clash, err := overlaps(tx, c, other)
+		if err != nil {
+			return err
+		}
+		if clash {
+			return fmt.Errorf("instructor %d already teaches class %s %s: %w", c.Instructor, other.ID, schedule(other), ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+// checkUnassigned fails when the instructor still teaches some class
+func checkUnassigned(tx Tx, ID int) error {
+	classes, err := tx.GetClasses()
+	if err != nil {
+		return err
+	}
+	for _, c := range classes {
+		if c.Instructor == ID {
+			return fmt.Errorf("instructor %d teaches class %s: %w", ID, c.ID, ErrConflict)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// WatchNoShows marks the bookings whose check-in window closed as no-shows,
+// for every tenant, every `every`, until the context is done
+func WatchNoShows(ctx context.Context, s Storage, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tenants, err := s.Tenants()
+			if err != nil {
+				log.Printf("unable to list the tenants: %s", err)
+				continue
+			}
+			for _, tenant := range tenants {
+				marked, err := s.ForTenant(tenant).MarkNoShows()
+				if err != nil {
+					log.Printf("unable to mark no-shows of tenant %q: %s", tenant, err)
+				} else if len(marked) > 0 {
+					log.Printf("marked %d booking(s) as no-show for tenant %q", len(marked), tenant)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+func TestWatchNoShows(t *testing.T) {
+	s := storage.NewVolatileStorage(storage.WithSeed(storage.DefaultSeed()))
+	defer s.Close()
+	ID, err := s.AddBooking(&models.Booking{Customer: "Foo", Class: "PI0001", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		storage.WatchNoShows(ctx, s, 10*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if b, _ := s.GetBooking(ID); b.Status == models.BookingNoShow {
+			return
+		}
+	}
+	t.Errorf("booking %d wasn't marked as no-show", ID)
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time, storages use it for any logic depending
+// on the wall clock so that tests can control it
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator creates the identifiers of new classes out of their name
+type IDGenerator interface {
+	NewID(name string) string
+}
+
+// Option configures a storage at creation time
+type Option func(*options)
+
+type options struct {
+	clock Clock
+	ids   IDGenerator
+	seed  *Seed
+
+	// check-in opens `checkInBefore` the booked time and closes `checkInAfter` it
+	checkInBefore time.Duration
+	checkInAfter  time.Duration
+
+	// more than `noShowLimit` no-shows within `noShowWindow` get a ban
+	noShowLimit  int
+	noShowWindow time.Duration
+	banDuration  time.Duration
+
+	// VolatileStorage only
+	snapshot      string
+	snapshotEvery time.Duration
+}
+
+// WithClock sets the clock used by the storage, the system clock is used
+// by default
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithIDGenerator sets the generator of class identifiers, by default
+// identifiers are random, e.g. `BA0001`
+func WithIDGenerator(g IDGenerator) Option {
+	return func(o *options) {
+		o.ids = g
+	}
+}
+
+// WithCheckInWindow sets how long before and after the booked time customers
+// can check in, by default one hour either way. Bookings still unchecked once
+// the window closes are no-shows.
+func WithCheckInWindow(before, after time.Duration) Option {
+	return func(o *options) {
+		o.checkInBefore = before
+		o.checkInAfter = after
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock:         systemClock{},
+		ids:           NewRandomIDGenerator(time.Now().UnixNano()),
+		seed:          &Seed{},
+		checkInBefore: time.Hour,
+		checkInAfter:  time.Hour,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// Now implements Storage.Now for all the storages
+func (o *options) Now() time.Time {
+	return o.clock.Now()
+}
+
+// systemClock is the Clock telling the actual time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// randomIDs generates random identifiers with its own source of randomness
+type randomIDs struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRandomIDGenerator returns the default IDGenerator, the same seed
+// always produces the same sequence of identifiers
+func NewRandomIDGenerator(seed int64) IDGenerator {
+	return &randomIDs{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (g *randomIDs) NewID(name string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return makeID(g.rnd, name)
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Room rules
+
+	Rooms belong to a location and host one session at a time, their
+	capacity caps the one of the classes they host. Sessions can follow each
+	other back to back, one ending when the next starts.
+*/
+
+// checkLocation fails when the location of the room doesn't exist
+func checkLocation(tx Tx, r *models.Room) error {
+	_, err := tx.GetLocation(r.Location)
+	return err
+}
+
+// checkRoom validates the assignment of the class `c` to its room, `ID` is
+// the identifier of the class when it's being updated
+func checkRoom(tx Tx, c *models.Class, ID string) error {
+	if c.Room == 0 {
+		return nil
+	}
+	if _, err := tx.GetRoom(c.Room); err != nil {
+		return err
+	}
+
+	classes, err := tx.GetClasses()
+	if err != nil {
+		return err
+	}
+	for _, other := range classes {
+		if other.ID == ID || other.Room != c.Room {
+			continue
+		}
+		clash, err := overlaps(tx, c, other)
+		if err != nil {
+			return err
+		}
+		if clash {
+			return fmt.Errorf("room %d already hosts class %s %s: %w", c.Room, other.ID, schedule(other), ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+// checkNoRooms fails when the location still has some room
+func checkNoRooms(tx Tx, ID int) error {
+	rooms, err := tx.GetRooms()
+	if err != nil {
+		return err
+	}
+	for _, r := range rooms {
+		if r.Location == ID {
+			return fmt.Errorf("location %d has room %d: %w", ID, r.ID, ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+// checkRoomUnused fails when the room still hosts some class
+func checkRoomUnused(tx Tx, ID int) error {
+	classes, err := tx.GetClasses()
+	if err != nil {
+		return err
+	}
+	for _, c := range classes {
+		if c.Room == ID {
+			return fmt.Errorf("room %d hosts class %s: %w", ID, c.ID, ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+// roomCapacity returns a copy of the class whose capacity is lowered to the
+// one of its room, when that's the smaller one
+func roomCapacity(tx Tx, c *models.Class) (*models.Class, error) {
+	if c.Room == 0 {
+		return c, nil
+	}
+	r, err := tx.GetRoom(c.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	capped := *c
+	if r.Capacity > 0 && (capped.Capacity <= 0 || r.Capacity < capped.Capacity) {
+		capped.Capacity = r.Capacity
+	}
+	return &capped, nil
+}
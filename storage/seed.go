@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/masci/go-rest-playground/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Seed is the initial data loaded in a storage at creation time. Loading
+// the same seed more than once is harmless, resources already stored are
+// left untouched.
+type Seed struct {
+	Classes []*models.Class `json:"classes"`
+}
+
+// DefaultSeed returns a copy of the fixtures used in tests and demos
+func DefaultSeed() *Seed {
+	seed := &Seed{}
+	for _, item := range classes {
+		c := *item // fixtures are shared, every seed gets its own copy
+		seed.Classes = append(seed.Classes, &c)
+	}
+
+	return seed
+}
+
+// LoadSeed reads a seed from a JSON or YAML file, according to its extension,
+// e.g.:
+//
+//	classes:
+//	  - ID: PI0001
+//	    name: Pilates
+//	    start_date: 2020-01-29T00:00:00Z
+//	    end_date: 2020-02-28T00:00:00Z
+//	    capacity: 20
+func LoadSeed(path string) (*Seed, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// models only describe their JSON representation, so the YAML document
+		// is converted to JSON before decoding it
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid seed file %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("invalid seed file %s: %w", path, err)
+		}
+	}
+
+	seed := &Seed{}
+	if err := json.Unmarshal(data, seed); err != nil {
+		return nil, fmt.Errorf("invalid seed file %s: %w", path, err)
+	}
+
+	// identifiers are what makes loading the seed idempotent
+	for i, c := range seed.Classes {
+		if c.ID == "" {
+			return nil, fmt.Errorf("invalid seed file %s: class #%d has no ID", path, i+1)
+		}
+	}
+
+	return seed, nil
+}
+
+// WithSeed loads the given data in the storage at creation time, by default
+// storages are created empty
+func WithSeed(seed *Seed) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
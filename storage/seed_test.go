@@ -0,0 +1,78 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/masci/go-rest-playground/storage"
+)
+
+func TestLoadSeed(t *testing.T) {
+	for _, path := range []string{"testdata/seed.json", "testdata/seed.yaml"} {
+		t.Run(path, func(t *testing.T) {
+			seed, err := storage.LoadSeed(path)
+			if err != nil {
+				t.Fatalf("got %s", err)
+			}
+			if len(seed.Classes) != 2 {
+				t.Fatalf("got %d, want %d", len(seed.Classes), 2)
+			}
+			c := seed.Classes[0]
+			if c.ID != "CR0001" || c.Name != "Crossfit" || c.Capacity != 100 {
+				t.Errorf("unexpected class: %+v", c)
+			}
+			if got := c.EndDate.Format("2006-01-02"); got != "2022-02-28" {
+				t.Errorf("got %s, want %s", got, "2022-02-28")
+			}
+		})
+	}
+
+	// errors
+	for _, path := range []string{"testdata/seed_no_id.json", "testdata/missing.json"} {
+		if _, err := storage.LoadSeed(path); err == nil {
+			t.Errorf("%s: got nil, want error", path)
+		}
+	}
+}
+
+func TestSeedIsOptional(t *testing.T) {
+	for name, s := range map[string]storage.Storage{
+		"volatile": storage.NewVolatileStorage(),
+		"sqlite":   storage.NewSqliteStorage(":memory:"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer s.Close()
+			if list, _ := s.GetClasses(); len(list) != 0 {
+				t.Errorf("got %d, want %d", len(list), 0)
+			}
+		})
+	}
+}
+
+func TestSeedIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	seed, err := storage.LoadSeed("testdata/seed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// first run
+	s := storage.NewSqliteStorage(path, storage.WithSeed(seed))
+	c, _ := s.GetClass("CR0001")
+	c.Capacity = 50
+	if err := s.UpdateClass(c.ID, c); err != nil {
+		t.Errorf("got %s", err)
+	}
+	s.Close()
+
+	// running again doesn't duplicate the classes nor overwrite changes
+	s = storage.NewSqliteStorage(path, storage.WithSeed(seed))
+	defer s.Close()
+	list, _ := s.GetClasses()
+	if len(list) != 2 {
+		t.Errorf("got %d, want %d", len(list), 2)
+	}
+	if c, _ = s.GetClass("CR0001"); c.Capacity != 50 {
+		t.Errorf("got %d, want %d", c.Capacity, 50)
+	}
+}
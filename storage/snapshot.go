@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Snapshots
+
+	VolatileStorage can periodically save its data to a JSON file and restore
+	it at startup, to get some durability without giving up on speed. Files
+	are written in full and atomically renamed, so that a crash while saving
+	never leaves a corrupted snapshot behind.
+*/
+
+// WithSnapshot makes a VolatileStorage restore its data from the file at
+// `path` when created, if the file exists, and save it back every `every`
+// and on Close. Periodic snapshots are disabled when `every` is zero.
+// Other storages ignore this option.
+func WithSnapshot(path string, every time.Duration) Option {
+	return func(o *options) {
+		o.snapshot = path
+		o.snapshotEvery = every
+	}
+}
+
+// snapshot is the content of a snapshot file, holding the data of the
+// default tenant along with the snapshots of the other ones
+type snapshot struct {
+	Classes       []*models.Class   `json:"classes"`
+	Bookings      []*models.Booking `json:"bookings"`
+	LastBookingID int               `json:"last_booking_id"`
+	Bans          []*models.Ban     `json:"bans"`
+	LastBanID     int               `json:"last_ban_id"`
+
+	Instructors      []*models.Instructor `json:"instructors"`
+	LastInstructorID int                  `json:"last_instructor_id"`
+
+	Locations      []*models.Location `json:"locations"`
+	LastLocationID int                `json:"last_location_id"`
+	Rooms          []*models.Room     `json:"rooms"`
+	LastRoomID     int                `json:"last_room_id"`
+
+	Blackouts      []*models.Blackout `json:"blackouts"`
+	LastBlackoutID int                `json:"last_blackout_id"`
+
+	Tenants map[string]*snapshot `json:"tenants,omitempty"`
+}
+
+// Snapshot saves the data of every tenant to the file at `path`
+func (s *VolatileStorage) Snapshot(path string) error {
+	s.mu.RLock()
+	snap := s.tenants[""].snapshot()
+	for ID, d := range s.tenants {
+		if ID == "" {
+			continue
+		}
+		if snap.Tenants == nil {
+			snap.Tenants = map[string]*snapshot{}
+		}
+		snap.Tenants[ID] = d.snapshot()
+	}
+	data, err := json.Marshal(snap)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// snapshot copies the data of a tenant
+func (d *volatileData) snapshot() *snapshot {
+	snap := &snapshot{
+		LastBookingID:    d.last_booking_id,
+		LastBanID:        d.last_ban_id,
+		LastInstructorID: d.last_instructor_id,
+		LastLocationID:   d.last_location_id,
+		LastRoomID:       d.last_room_id,
+		LastBlackoutID:   d.last_blackout_id,
+	}
+	for _, ID := range d.class_ids {
+		snap.Classes = append(snap.Classes, d.classes[ID])
+	}
+	for _, b := range d.bookings {
+		snap.Bookings = append(snap.Bookings, b)
+	}
+	sort.Slice(snap.Bookings, func(i, j int) bool { return snap.Bookings[i].ID < snap.Bookings[j].ID })
+	for _, b := range d.bans {
+		snap.Bans = append(snap.Bans, b)
+	}
+	sort.Slice(snap.Bans, func(i, j int) bool { return snap.Bans[i].ID < snap.Bans[j].ID })
+	for _, i := range d.instructors {
+		snap.Instructors = append(snap.Instructors, i)
+	}
+	sort.Slice(snap.Instructors, func(i, j int) bool { return snap.Instructors[i].ID < snap.Instructors[j].ID })
+	for _, l := range d.locations {
+		snap.Locations = append(snap.Locations, l)
+	}
+	sort.Slice(snap.Locations, func(i, j int) bool { return snap.Locations[i].ID < snap.Locations[j].ID })
+	for _, r := range d.rooms {
+		snap.Rooms = append(snap.Rooms, r)
+	}
+	sort.Slice(snap.Rooms, func(i, j int) bool { return snap.Rooms[i].ID < snap.Rooms[j].ID })
+	for _, b := range d.blackouts {
+		snap.Blackouts = append(snap.Blackouts, b)
+	}
+	sort.Slice(snap.Blackouts, func(i, j int) bool { return snap.Blackouts[i].ID < snap.Blackouts[j].ID })
+
+	return snap
+}
+
+// restore loads the data saved in the snapshot file at `path`, a missing
+// file is not an error as there's nothing to restore the first time
+func (s *VolatileStorage) restore(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	snap := snapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("invalid snapshot file %s: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[""].restore(&snap)
+	for ID, tenant := range snap.Tenants {
+		d := newVolatileData(s.options)
+		d.restore(tenant)
+		s.tenants[ID] = d
+	}
+
+	return nil
+}
+
+// restore loads the data of a tenant
+func (d *volatileData) restore(snap *snapshot) {
+	for _, c := range snap.Classes {
+		d.putClass(c)
+	}
+	for _, b := range snap.Bookings {
+		upgradeBooking(b)
+		d.bookings[b.ID] = b
+	}
+	d.last_booking_id = snap.LastBookingID
+	for _, b := range snap.Bans {
+		d.bans[b.ID] = b
+	}
+	d.last_ban_id = snap.LastBanID
+	for _, i := range snap.Instructors {
+		d.instructors[i.ID] = i
+	}
+	d.last_instructor_id = snap.LastInstructorID
+	for _, l := range snap.Locations {
+		d.locations[l.ID] = l
+	}
+	d.last_location_id = snap.LastLocationID
+	for _, r := range snap.Rooms {
+		d.rooms[r.ID] = r
+	}
+	d.last_room_id = snap.LastRoomID
+	for _, b := range snap.Blackouts {
+		d.blackouts[b.ID] = b
+	}
+	d.last_blackout_id = snap.LastBlackoutID
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory
+// and then renames it, which is atomic on POSIX systems
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // noop once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// snapshotter saves the snapshots of a VolatileStorage in background
+type snapshotter struct {
+	s    *VolatileStorage
+	path string
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func startSnapshots(s *VolatileStorage, path string, every time.Duration) *snapshotter {
+	sn := &snapshotter{s: s, path: path, done: make(chan struct{})}
+	if every <= 0 {
+		return sn
+	}
+
+	sn.wg.Add(1)
+	go func() {
+		defer sn.wg.Done()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Snapshot(path); err != nil {
+					log.Printf("unable to save snapshot %s: %s", path, err)
+				}
+			case <-sn.done:
+				return
+			}
+		}
+	}()
+
+	return sn
+}
+
+// stop waits for the background saves to finish and takes a last snapshot
+func (sn *snapshotter) stop() error {
+	close(sn.done)
+	sn.wg.Wait()
+
+	return sn.s.Snapshot(sn.path)
+}
@@ -0,0 +1,150 @@
+package storage_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+func TestSnapshotOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	// nothing to restore the first time
+	s := storage.NewVolatileStorage(storage.WithSnapshot(path, 0), storage.WithSeed(storage.DefaultSeed()))
+	c := &models.Class{Name: "Crossfit"}
+	s.AddClass(c)
+	s.AddBooking(&models.Booking{Customer: "Foo", Class: "PI0001", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)})
+	s.AddBooking(&models.Booking{Customer: "Bar", Class: "PI0001", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)})
+	s.DeleteBooking(2)
+	s.AddBan(&models.Ban{Customer: "Bar", ExpiresAt: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)})
+	s.AddInstructor(&models.Instructor{Name: "Jane"})
+	s.AddLocation(&models.Location{Name: "Downtown"})
+	s.AddRoom(&models.Room{Location: 1, Name: "Studio 1", Capacity: 20})
+	s.AddBlackout(&models.Blackout{StartDate: time.Date(2020, 2, 10, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2020, 2, 11, 0, 0, 0, 0, time.UTC), Location: 1})
+	acme := &models.Class{Name: "Yoga"}
+	s.ForTenant("acme").AddClass(acme)
+	if err := s.Close(); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// data is restored, seed included
+	s = storage.NewVolatileStorage(storage.WithSnapshot(path, 0), storage.WithSeed(storage.DefaultSeed()))
+	defer s.Close()
+	classes, _ := s.GetClasses()
+	if len(classes) != 5 || classes[4].ID != c.ID {
+		t.Errorf("got %d classes, want %d ending with %s", len(classes), 5, c.ID)
+	}
+	if b, err := s.GetBooking(1); err != nil || b.Customer != "Foo" {
+		t.Errorf("got %v %v", b, err)
+	}
+	if ban, err := s.GetBan(1); err != nil || ban.Customer != "Bar" {
+		t.Errorf("got %v %v", ban, err)
+	}
+	if i, err := s.GetInstructor(1); err != nil || i.Name != "Jane" {
+		t.Errorf("got %v %v", i, err)
+	}
+	if r, err := s.GetRoom(1); err != nil || r.Capacity != 20 {
+		t.Errorf("got %v %v", r, err)
+	}
+	if l, err := s.GetLocation(1); err != nil || l.Name != "Downtown" {
+		t.Errorf("got %v %v", l, err)
+	}
+	if b, err := s.GetBlackout(1); err != nil || b.Location != 1 {
+		t.Errorf("got %v %v", b, err)
+	}
+	if _, err := s.ForTenant("acme").GetClass(acme.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if _, err := s.GetClass(acme.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("got %v, want %s", err, storage.ErrNotFound)
+	}
+
+	// the ID of the deleted booking isn't reused
+	b := &models.Booking{Customer: "Baz", Class: "PI0001", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)}
+	if ID, _ := s.AddBooking(b); ID != 3 {
+		t.Errorf("got %d, want %d", ID, 3)
+	}
+
+	// no temporary files are left behind
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(files) != 1 {
+		t.Errorf("got %d files, want %d", len(files), 1)
+	}
+}
+
+func TestPeriodicSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	s := storage.NewVolatileStorage(storage.WithSnapshot(path, 10*time.Millisecond))
+	defer s.Close()
+	c := &models.Class{Name: "Crossfit"}
+	s.AddClass(c)
+
+	// wait for the class to be saved
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, _ := ioutil.ReadFile(path)
+		if strings.Contains(string(data), c.ID) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("class %s not found in snapshot: %s", c.ID, data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInvalidSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := storage.OpenVolatileStorage(storage.WithSnapshot(path, 0)); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("got %v, want an error about %s", err, path)
+	}
+	// the broken file is left untouched for inspection
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "{" {
+		t.Errorf("got %q %v", data, err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("got nil, want panic")
+		}
+	}()
+	storage.NewVolatileStorage(storage.WithSnapshot(path, 0))
+}
+
+func TestTruncatedSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	s := storage.NewVolatileStorage(storage.WithSnapshot(path, 0))
+	s.AddClass(&models.Class{Name: "Crossfit"})
+	if err := s.Close(); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// a snapshot cut short, e.g. by a full disk
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = storage.OpenVolatileStorage(storage.WithSnapshot(path, 0))
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("got %v, want an error about %s", err, path)
+	}
+	if s != nil {
+		t.Errorf("got %v, want nil", s)
+	}
+}
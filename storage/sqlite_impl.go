@@ -1,14 +1,25 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 	"github.com/masci/go-rest-playground/models"
 )
 
-var schema = `
-CREATE TABLE class (
+// migrations create and upgrade the database schema. The version of the
+// schema is saved in the `user_version` pragma, so that every migration
+// runs only once; new migrations must be appended to the list.
+var migrations = []string{
+	// the initial schema
+	`
+CREATE TABLE IF NOT EXISTS class (
 	id TEXT PRIMARY KEY,
     name TEXT,
 	start_date DATETIME,
@@ -16,32 +27,216 @@ CREATE TABLE class (
     capacity INTEGER
 );
 
-CREATE TABLE booking (
+CREATE TABLE IF NOT EXISTS booking (
 	id INTEGER PRIMARY KEY,
 	date DATETIME,
 	customer TEXT,
 	class TEXT
 );
-`
+`,
+	// booking ids are assigned by the database and never reused
+	`
+CREATE TABLE booking_v2 (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	date DATETIME,
+	customer TEXT,
+	class TEXT
+);
+INSERT INTO booking_v2(id, date, customer, class) SELECT id, date, customer, class FROM booking;
+DROP TABLE booking;
+ALTER TABLE booking_v2 RENAME TO booking;
+`,
+	// bookings are cancelled instead of deleted
+	`
+ALTER TABLE booking ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
+ALTER TABLE booking ADD COLUMN cancelled_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE booking ADD COLUMN cancelled_at DATETIME;
+ALTER TABLE booking ADD COLUMN cancel_reason TEXT NOT NULL DEFAULT '';
+`,
+	// classes have a cancellation policy, applied to bookings when cancelled
+	`
+ALTER TABLE class ADD COLUMN cancel_cutoff INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE class ADD COLUMN cancel_fee INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE class ADD COLUMN cancel_forfeit_credit BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE booking ADD COLUMN cancellation TEXT NOT NULL DEFAULT '';
+ALTER TABLE booking ADD COLUMN cancel_fee INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE booking ADD COLUMN credit_forfeited BOOLEAN NOT NULL DEFAULT 0;
+UPDATE booking SET cancellation='free' WHERE status='cancelled';
+`,
+	// bookings record when the customer checked in
+	`
+ALTER TABLE booking ADD COLUMN checked_in_at DATETIME;
+`,
+	// customers missing too many sessions get banned
+	`
+CREATE TABLE ban (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	customer TEXT,
+	reason TEXT,
+	created_at DATETIME,
+	expires_at DATETIME,
+	lifted_by TEXT NOT NULL DEFAULT '',
+	lifted_at DATETIME
+);
+`,
+	// classes are taught by instructors
+	`
+CREATE TABLE instructor (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	email TEXT NOT NULL DEFAULT ''
+);
+ALTER TABLE class ADD COLUMN instructor INTEGER NOT NULL DEFAULT 0;
+`,
+	// classes take place in the rooms of the locations
+	`
+CREATE TABLE location (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT,
+	address TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE room (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	location INTEGER,
+	name TEXT,
+	capacity INTEGER NOT NULL DEFAULT 0
+);
+ALTER TABLE class ADD COLUMN room INTEGER NOT NULL DEFAULT 0;
+`,
+	// every row belongs to a tenant, the default one being ''
+	`
+ALTER TABLE class ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE booking ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE ban ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE instructor ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE location ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE room ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+CREATE INDEX class_tenant ON class(tenant);
+CREATE INDEX booking_tenant ON booking(tenant);
+CREATE INDEX ban_tenant ON ban(tenant);
+CREATE INDEX instructor_tenant ON instructor(tenant);
+CREATE INDEX location_tenant ON location(tenant);
+CREATE INDEX room_tenant ON room(tenant);
+`,
+	// time zones of classes and locations
+	`
+ALTER TABLE class ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE location ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+`,
+	// blackout periods
+	`
+CREATE TABLE blackout (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	start_date DATETIME,
+	end_date DATETIME,
+	reason TEXT NOT NULL DEFAULT '',
+	location INTEGER NOT NULL DEFAULT 0,
+	class TEXT NOT NULL DEFAULT '',
+	tenant TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX blackout_tenant ON blackout(tenant);
+`,
+	// classes can be booked within a window before they start
+	`
+ALTER TABLE class ADD COLUMN booking_opens_days INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE class ADD COLUMN booking_closes_minutes INTEGER NOT NULL DEFAULT 0;
+`,
+	// tenants provisioned before having any data
+	`
+CREATE TABLE tenant (
+	id TEXT PRIMARY KEY
+);
+`,
+	// sessions start at a time of day and last a while
+	`
+ALTER TABLE class ADD COLUMN start_time TEXT NOT NULL DEFAULT '';
+ALTER TABLE class ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;
+`,
+	// tenants are known from their first write, so that they can be looked
+	// up in the tenant table alone
+	`
+INSERT OR IGNORE INTO tenant(id)
+SELECT tenant FROM class UNION SELECT tenant FROM booking UNION SELECT tenant FROM ban
+UNION SELECT tenant FROM instructor UNION SELECT tenant FROM location UNION SELECT tenant FROM room
+UNION SELECT tenant FROM blackout;
+DELETE FROM tenant WHERE id='';
+CREATE TRIGGER class_adds_tenant AFTER INSERT ON class WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+CREATE TRIGGER booking_adds_tenant AFTER INSERT ON booking WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+CREATE TRIGGER ban_adds_tenant AFTER INSERT ON ban WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+CREATE TRIGGER instructor_adds_tenant AFTER INSERT ON instructor WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+CREATE TRIGGER location_adds_tenant AFTER INSERT ON location WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+CREATE TRIGGER room_adds_tenant AFTER INSERT ON room WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+CREATE TRIGGER blackout_adds_tenant AFTER INSERT ON blackout WHEN NEW.tenant != '' BEGIN
+	INSERT OR IGNORE INTO tenant(id) VALUES (NEW.tenant);
+END;
+`,
+}
 
 // SqliteStorage implements the Storage interface saving data
 // in a SQLite database on disk.
+//
+// Every table has a tenant column and the queries only touch the rows of
+// the tenant of the storage. Class identifiers are unique across tenants,
+// being the primary key of their table.
 type SqliteStorage struct {
-	db *sqlx.DB
+	sqliteTx
+	db   *sqlx.DB
+	view bool // views don't own the database
 }
 
-// NewSqliteStorage creates the database on file and loads the initial fixtures.
-// The path to the database file is passed by the caller with the `path` parameter.
-func NewSqliteStorage(path string) Storage {
+// sqliteConn is implemented by both sqlx.DB and sqlx.Tx
+type sqliteConn interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+}
+
+// sqliteTx runs the queries either on the database, one statement at a time,
+// or within a transaction
+type sqliteTx struct {
+	*options
+	conn   sqliteConn
+	tenant string
+}
+
+// mapper names the fields of the models the same way sqlx does
+var mapper = reflectx.NewMapperFunc("db", sqlx.NameMapper)
+
+// NewSqliteStorage creates the database on file, unless it already exists, and
+// loads the seed if any. The path to the database file is passed by the caller
+// with the `path` parameter.
+func NewSqliteStorage(path string, opts ...Option) Storage {
+	// the tenant column has no field in the models
+	db := sqlx.MustConnect(sqliteDriver, sqliteDSN(path)).Unsafe()
 	d := &SqliteStorage{
-		db: sqlx.MustConnect("sqlite3", path),
+		sqliteTx: sqliteTx{options: newOptions(opts), conn: db},
+		db:       db,
+	}
+	if path == ":memory:" {
+		// every connection would open a different database
+		d.db.SetMaxOpenConns(1)
+	}
+	if err := migrate(d.db); err != nil {
+		panic(err)
 	}
-	d.db.Exec(schema)
 
 	tx := d.db.MustBegin()
-	for _, item := range classes {
+	for _, item := range d.seed.Classes {
 		tx.NamedExec(
-			"INSERT INTO class(id, name, start_date, end_date, capacity) VALUES (:id, :name, :start_date, :end_date, :capacity)",
+			"INSERT OR IGNORE INTO class(id, name, start_date, end_date, capacity, instructor, room, time_zone, cancel_cutoff, cancel_fee, cancel_forfeit_credit, booking_opens_days, booking_closes_minutes, start_time, duration) VALUES (:id, :name, :start_date, :end_date, :capacity, :instructor, :room, :time_zone, :cancel_cutoff, :cancel_fee, :cancel_forfeit_credit, :booking_opens_days, :booking_closes_minutes, :start_time, :duration)",
 			item,
 		)
 	}
@@ -50,125 +245,736 @@ func NewSqliteStorage(path string) Storage {
 	return d
 }
 
+// ForTenant returns a view sharing the database
+func (s *SqliteStorage) ForTenant(ID string) Storage {
+	return &SqliteStorage{
+		sqliteTx: sqliteTx{options: s.options, conn: s.db, tenant: ID},
+		db:       s.db,
+		view:     true,
+	}
+}
+
+func (s *SqliteStorage) AddTenant(ID string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO tenant(id) VALUES ($1)", ID)
+	return err
+}
+
+func (s *SqliteStorage) HasTenant(ID string) (bool, error) {
+	if ID == "" {
+		return true, nil
+	}
+	known := false
+	err := s.db.Get(&known, "SELECT EXISTS(SELECT 1 FROM tenant WHERE id=$1)", ID)
+	return known, err
+}
+
+// Tenants lists the tenants provisioned or written to, along with the
+// default one
+func (s *SqliteStorage) Tenants() ([]string, error) {
+	tenants := []string{}
+	err := s.db.Select(&tenants, "SELECT '' UNION SELECT id FROM tenant ORDER BY 1")
+	return tenants, err
+}
+
+// WithTx runs `fn` within a transaction, which is started again when the
+// database is locked by another connection
+func (s *SqliteStorage) WithTx(ctx context.Context, fn func(Tx) error) error {
+	return retryBusy(func() error {
+		tx, err := s.db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(&sqliteTx{options: s.options, conn: tx, tenant: s.tenant}); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// AddBooking validates and creates the booking within a transaction, so that
+// concurrent requests can't book a class that's being deleted
+func (s *SqliteStorage) AddBooking(b *models.Booking) (int, error) {
+	var ID int
+	err := s.WithTx(context.Background(), func(tx Tx) error {
+		var err error
+		ID, err = tx.AddBooking(b)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return ID, nil
+}
+
+// UpdateBooking validates and saves the changes within a transaction, so
+// that the booking can't be moved to a day that's being booked up
+func (s *SqliteStorage) UpdateBooking(ID int, b *models.Booking) error {
+	return s.WithTx(context.Background(), func(tx Tx) error {
+		return tx.UpdateBooking(ID, b)
+	})
+}
+
+// CancelBooking reads and updates the booking within a transaction
+func (s *SqliteStorage) CancelBooking(ID int, by, reason string) (b *models.Booking, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		b, err = tx.CancelBooking(ID, by, reason)
+		return err
+	})
+	return b, err
+}
+
+// CheckIn reads and updates the booking within a transaction
+func (s *SqliteStorage) CheckIn(ID int) (b *models.Booking, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		b, err = tx.CheckIn(ID)
+		return err
+	})
+	return b, err
+}
+
+// MarkNoShows reads and updates the bookings within a transaction
+func (s *SqliteStorage) MarkNoShows() (marked []*models.Booking, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		marked, err = tx.MarkNoShows()
+		return err
+	})
+	return marked, err
+}
+
+// LiftBan reads and updates the ban within a transaction
+func (s *SqliteStorage) LiftBan(ID int, by string) (ban *models.Ban, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		ban, err = tx.LiftBan(ID, by)
+		return err
+	})
+	return ban, err
+}
+
+// AddClass checks the instructor and the room are free and creates the class
+// within a transaction
+func (s *SqliteStorage) AddClass(c *models.Class) (ID string, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		ID, err = tx.AddClass(c)
+		return err
+	})
+	return ID, err
+}
+
+// UpdateClass checks the instructor and the room are free and updates the
+// class within a transaction
+func (s *SqliteStorage) UpdateClass(ID string, c *models.Class) error {
+	return s.WithTx(context.Background(), func(tx Tx) error {
+		return tx.UpdateClass(ID, c)
+	})
+}
+
+// DeleteInstructor checks the instructor isn't teaching and deletes them
+// within a transaction
+func (s *SqliteStorage) DeleteInstructor(ID int) error {
+	return s.WithTx(context.Background(), func(tx Tx) error {
+		return tx.DeleteInstructor(ID)
+	})
+}
+
+// DeleteLocation checks the location has no rooms and deletes it within a
+// transaction
+func (s *SqliteStorage) DeleteLocation(ID int) error {
+	return s.WithTx(context.Background(), func(tx Tx) error {
+		return tx.DeleteLocation(ID)
+	})
+}
+
+// AddRoom checks the location exists and creates the room within a
+// transaction
+func (s *SqliteStorage) AddRoom(r *models.Room) (ID int, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		ID, err = tx.AddRoom(r)
+		return err
+	})
+	return ID, err
+}
+
+// UpdateRoom checks the location exists and updates the room within a
+// transaction
+func (s *SqliteStorage) UpdateRoom(ID int, r *models.Room) error {
+	return s.WithTx(context.Background(), func(tx Tx) error {
+		return tx.UpdateRoom(ID, r)
+	})
+}
+
+// DeleteRoom checks the room hosts no class and deletes it within a
+// transaction
+func (s *SqliteStorage) DeleteRoom(ID int) error {
+	return s.WithTx(context.Background(), func(tx Tx) error {
+		return tx.DeleteRoom(ID)
+	})
+}
+
+func (s *SqliteStorage) AddBlackout(b *models.Blackout) (cancelled []*models.Booking, err error) {
+	err = s.WithTx(context.Background(), func(tx Tx) error {
+		cancelled, err = tx.AddBlackout(b)
+		return err
+	})
+	return cancelled, err
+}
+
 /*
 	Class management functions
 */
 
-func (s *SqliteStorage) AddClass(c *models.Class) (string, error) {
-	c.ID = makeID(c.Name)
+func (s *sqliteTx) AddClass(c *models.Class) (string, error) {
+	if err := checkClass(s, c, ""); err != nil {
+		return "", err
+	}
+	ID, err := newClassID(s.ids, c.Name, func(ID string) (bool, error) {
+		var count int
+		// identifiers can't be reused by other tenants either
+		err := s.conn.Get(&count, "SELECT COUNT(*) FROM class WHERE id=$1", ID)
+		return count > 0, err
+	})
+	if err != nil {
+		return "", err
+	}
 
-	_, err := s.db.NamedExec(
-		"INSERT INTO class(id, name, start_date, end_date, capacity) VALUES (:id, :name, :start_date, :end_date, :capacity)",
-		c,
+	c.ID = ID
+	_, err = s.conn.NamedExec(
+		"INSERT INTO class(id, name, start_date, end_date, capacity, instructor, room, time_zone, cancel_cutoff, cancel_fee, cancel_forfeit_credit, booking_opens_days, booking_closes_minutes, start_time, duration, tenant) VALUES (:id, :name, :start_date, :end_date, :capacity, :instructor, :room, :time_zone, :cancel_cutoff, :cancel_fee, :cancel_forfeit_credit, :booking_opens_days, :booking_closes_minutes, :start_time, :duration, :tenant)",
+		s.scoped(c),
 	)
 
 	return c.ID, err
 }
 
-func (s *SqliteStorage) GetClasses() ([]*models.Class, error) {
+func (s *sqliteTx) GetClasses() ([]*models.Class, error) {
 	classes := []*models.Class{}
 
-	err := s.db.Select(&classes, `SELECT * FROM class`)
+	err := s.conn.Select(&classes, `SELECT * FROM class WHERE tenant=$1 ORDER BY rowid`, s.tenant)
 
 	return classes, err
 }
 
-func (s *SqliteStorage) GetClass(ID string) (*models.Class, error) {
+func (s *sqliteTx) GetClass(ID string) (*models.Class, error) {
 	c := models.Class{}
-	err := s.db.Get(&c, "SELECT * FROM class WHERE id=$1", ID)
+	err := s.conn.Get(&c, "SELECT * FROM class WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Class", ID)
+	}
 
 	return &c, err
 }
 
-func (s *SqliteStorage) UpdateClass(ID string, c *models.Class) error {
+func (s *sqliteTx) UpdateClass(ID string, c *models.Class) error {
+	if err := checkClass(s, c, ID); err != nil {
+		return err
+	}
 
-	res, err := s.db.NamedExec(
-		"Update class SET name=:name, start_date=:start_date, end_date=:end_date, capacity=:capacity WHERE id=:id",
-		c,
+	c.ID = ID
+	res, err := s.conn.NamedExec(
+		"Update class SET name=:name, start_date=:start_date, end_date=:end_date, capacity=:capacity, instructor=:instructor, room=:room, time_zone=:time_zone, cancel_cutoff=:cancel_cutoff, cancel_fee=:cancel_fee, cancel_forfeit_credit=:cancel_forfeit_credit, booking_opens_days=:booking_opens_days, booking_closes_minutes=:booking_closes_minutes, start_time=:start_time, duration=:duration WHERE id=:id AND tenant=:tenant",
+		s.scoped(c),
 	)
 
-	// no rows affected
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		return fmt.Errorf("no class found with id: %s", ID)
+	if err != nil {
+		return err
 	}
 
-	return err
+	// no rows affected
+	return checkAffected(res, notFound("Class", ID))
 }
 
-func (s *SqliteStorage) DeleteClass(ID string) error {
-	_, err := s.db.Exec("DELETE from class WHERE id=$1", ID)
-	return err
+func (s *sqliteTx) DeleteClass(ID string) error {
+	res, err := s.conn.Exec("DELETE from class WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, notFound("Class", ID))
 }
 
 /*
 	Booking management functions
 */
 
-func (s *SqliteStorage) AddBooking(b *models.Booking) (int, error) {
+func (s *sqliteTx) AddBooking(b *models.Booking) (int, error) {
+	// inserting first takes the write lock, so that the class can't be
+	// changed by anybody else until the booking is validated
+	res, err := s.conn.NamedExec(
+		"INSERT INTO booking(date, customer, class, status, tenant) VALUES (:date, :customer, :class, 'confirmed', :tenant)",
+		s.scoped(b),
+	)
+	if err != nil {
+		return -1, err
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
 	// check wether the booking is valid
 	class, err := s.GetClass(b.Class)
 	if err != nil {
 		return -1, err
 	}
-	if !canBook(b, class) {
-		return -1, fmt.Errorf("class %s is not available at %s", class.Name, b.Date)
+	if class, err = roomCapacity(s, class); err != nil {
+		return -1, err
+	}
+	if err := checkBanned(s, s.clock, b.Customer); err != nil {
+		return -1, err
 	}
-
-	err = s.db.Get(&b.ID, "SELECT IFNULL( MAX(id), 0 ) from booking;") // this strategy won't reuse deleted ids
+	taken := []*models.Booking{}
+	err = s.conn.Select(&taken, "SELECT * FROM booking WHERE class=$1 AND id!=$2 AND tenant=$3", b.Class, ID, s.tenant)
 	if err != nil {
-		return 0, err
+		return -1, err
 	}
-	b.ID++
-
-	_, err = s.db.NamedExec(
-		"INSERT INTO booking(id, date, customer, class) VALUES (:id, :date, :customer, :class)",
-		b,
-	)
+	if err := checkBooking(s, s.clock, b, class, taken); err != nil {
+		return -1, err
+	}
+	b.ID = int(ID)
 
-	return b.ID, err
+	return b.ID, nil
 }
 
-func (s *SqliteStorage) GetBookings() ([]*models.Booking, error) {
+func (s *sqliteTx) GetBookings() ([]*models.Booking, error) {
 	bookings := []*models.Booking{}
 
-	err := s.db.Select(&bookings, `SELECT * FROM booking`)
+	err := s.conn.Select(&bookings, `SELECT * FROM booking WHERE tenant=$1 ORDER BY id`, s.tenant)
 
 	return bookings, err
 }
 
-func (s *SqliteStorage) GetBooking(ID int) (*models.Booking, error) {
+func (s *sqliteTx) GetBooking(ID int) (*models.Booking, error) {
 	b := models.Booking{}
-	err := s.db.Get(&b, "SELECT * FROM booking WHERE id=$1", ID)
+	err := s.conn.Get(&b, "SELECT * FROM booking WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Booking", ID)
+	}
 
 	return &b, err
 }
 
-func (s *SqliteStorage) UpdateBooking(ID int, c *models.Booking) error {
+func (s *sqliteTx) UpdateBooking(ID int, c *models.Booking) error {
+	old, err := s.GetBooking(ID)
+	if err != nil {
+		return err
+	}
+	if err := checkChange(s, s.clock, old, c); err != nil {
+		return err
+	}
 
-	res, err := s.db.NamedExec(
-		"Update booking SET date=:date, customer=:customer, class=:class WHERE id=:id",
-		c,
+	c.ID = ID
+	res, err := s.conn.NamedExec(
+		"Update booking SET date=:date, customer=:customer, class=:class, status=:status, cancelled_by=:cancelled_by, cancelled_at=:cancelled_at, cancel_reason=:cancel_reason, cancellation=:cancellation, cancel_fee=:cancel_fee, credit_forfeited=:credit_forfeited, checked_in_at=:checked_in_at WHERE id=:id AND tenant=:tenant",
+		s.scoped(c),
 	)
 
+	if err != nil {
+		return err
+	}
+
 	// no rows affected
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		return fmt.Errorf("no booking found with id: %d", ID)
+	return checkAffected(res, notFound("Booking", ID))
+}
+
+func (s *sqliteTx) DeleteBooking(ID int) error {
+	res, err := s.conn.Exec("DELETE from booking WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, notFound("Booking", ID))
+}
+
+func (s *sqliteTx) CancelBooking(ID int, by, reason string) (*models.Booking, error) {
+	return cancelBooking(s, s.clock, ID, by, reason)
+}
+
+func (s *sqliteTx) CheckIn(ID int) (*models.Booking, error) {
+	return checkIn(s, s.options, ID)
+}
+
+func (s *sqliteTx) MarkNoShows() ([]*models.Booking, error) {
+	return markNoShows(s, s.options)
+}
+
+/*
+	Ban management functions
+*/
+
+func (s *sqliteTx) AddBan(ban *models.Ban) (int, error) {
+	res, err := s.conn.NamedExec(
+		"INSERT INTO ban(customer, reason, created_at, expires_at, lifted_by, lifted_at, tenant) VALUES (:customer, :reason, :created_at, :expires_at, :lifted_by, :lifted_at, :tenant)",
+		s.scoped(ban),
+	)
+	if err != nil {
+		return -1, err
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	ban.ID = int(ID)
+
+	return ban.ID, nil
+}
+
+func (s *sqliteTx) GetBans() ([]*models.Ban, error) {
+	bans := []*models.Ban{}
+
+	err := s.conn.Select(&bans, `SELECT * FROM ban WHERE tenant=$1 ORDER BY id`, s.tenant)
+
+	return bans, err
+}
+
+func (s *sqliteTx) GetBan(ID int) (*models.Ban, error) {
+	ban := models.Ban{}
+	err := s.conn.Get(&ban, "SELECT * FROM ban WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Ban", ID)
+	}
+
+	return &ban, err
+}
+
+func (s *sqliteTx) UpdateBan(ID int, ban *models.Ban) error {
+	ban.ID = ID
+	res, err := s.conn.NamedExec(
+		"Update ban SET customer=:customer, reason=:reason, created_at=:created_at, expires_at=:expires_at, lifted_by=:lifted_by, lifted_at=:lifted_at WHERE id=:id AND tenant=:tenant",
+		s.scoped(ban),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	// no rows affected
+	return checkAffected(res, notFound("Ban", ID))
+}
+
+func (s *sqliteTx) LiftBan(ID int, by string) (*models.Ban, error) {
+	return liftBan(s, s.clock, ID, by)
+}
+
+/*
+	Instructor management functions
+*/
+
+func (s *sqliteTx) AddInstructor(i *models.Instructor) (int, error) {
+	res, err := s.conn.NamedExec("INSERT INTO instructor(name, email, tenant) VALUES (:name, :email, :tenant)", s.scoped(i))
+	if err != nil {
+		return -1, err
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	i.ID = int(ID)
+
+	return i.ID, nil
+}
+
+func (s *sqliteTx) GetInstructors() ([]*models.Instructor, error) {
+	instructors := []*models.Instructor{}
+
+	err := s.conn.Select(&instructors, `SELECT * FROM instructor WHERE tenant=$1 ORDER BY id`, s.tenant)
+
+	return instructors, err
+}
+
+func (s *sqliteTx) GetInstructor(ID int) (*models.Instructor, error) {
+	i := models.Instructor{}
+	err := s.conn.Get(&i, "SELECT * FROM instructor WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Instructor", ID)
+	}
+
+	return &i, err
+}
+
+func (s *sqliteTx) UpdateInstructor(ID int, i *models.Instructor) error {
+	i.ID = ID
+	res, err := s.conn.NamedExec("Update instructor SET name=:name, email=:email WHERE id=:id AND tenant=:tenant", s.scoped(i))
+
+	if err != nil {
+		return err
 	}
 
+	// no rows affected
+	return checkAffected(res, notFound("Instructor", ID))
+}
+
+func (s *sqliteTx) DeleteInstructor(ID int) error {
+	if _, err := s.GetInstructor(ID); err != nil {
+		return err
+	}
+	if err := checkUnassigned(s, ID); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Exec("DELETE from instructor WHERE id=$1 AND tenant=$2", ID, s.tenant)
 	return err
 }
 
-func (s *SqliteStorage) DeleteBooking(ID int) error {
-	_, err := s.db.Exec("DELETE from booking WHERE id=$1", ID)
+/*
+	Location management functions
+*/
+
+func (s *sqliteTx) AddLocation(l *models.Location) (int, error) {
+	if err := checkTimeZone(l.TimeZone); err != nil {
+		return -1, err
+	}
+	res, err := s.conn.NamedExec("INSERT INTO location(name, address, time_zone, tenant) VALUES (:name, :address, :time_zone, :tenant)", s.scoped(l))
+	if err != nil {
+		return -1, err
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	l.ID = int(ID)
+
+	return l.ID, nil
+}
+
+func (s *sqliteTx) GetLocations() ([]*models.Location, error) {
+	locations := []*models.Location{}
+
+	err := s.conn.Select(&locations, `SELECT * FROM location WHERE tenant=$1 ORDER BY id`, s.tenant)
+
+	return locations, err
+}
+
+func (s *sqliteTx) GetLocation(ID int) (*models.Location, error) {
+	l := models.Location{}
+	err := s.conn.Get(&l, "SELECT * FROM location WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Location", ID)
+	}
+
+	return &l, err
+}
+
+func (s *sqliteTx) UpdateLocation(ID int, l *models.Location) error {
+	if err := checkTimeZone(l.TimeZone); err != nil {
+		return err
+	}
+	l.ID = ID
+	res, err := s.conn.NamedExec("Update location SET name=:name, address=:address, time_zone=:time_zone WHERE id=:id AND tenant=:tenant", s.scoped(l))
+
+	if err != nil {
+		return err
+	}
+
+	// no rows affected
+	return checkAffected(res, notFound("Location", ID))
+}
+
+func (s *sqliteTx) DeleteLocation(ID int) error {
+	if _, err := s.GetLocation(ID); err != nil {
+		return err
+	}
+	if err := checkNoRooms(s, ID); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Exec("DELETE from location WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	return err
+}
+
+/*
+	Room management functions
+*/
+
+func (s *sqliteTx) AddRoom(r *models.Room) (int, error) {
+	if err := checkLocation(s, r); err != nil {
+		return -1, err
+	}
+
+	res, err := s.conn.NamedExec("INSERT INTO room(location, name, capacity, tenant) VALUES (:location, :name, :capacity, :tenant)", s.scoped(r))
+	if err != nil {
+		return -1, err
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	r.ID = int(ID)
+
+	return r.ID, nil
+}
+
+func (s *sqliteTx) GetRooms() ([]*models.Room, error) {
+	rooms := []*models.Room{}
+
+	err := s.conn.Select(&rooms, `SELECT * FROM room WHERE tenant=$1 ORDER BY id`, s.tenant)
+
+	return rooms, err
+}
+
+func (s *sqliteTx) GetRoom(ID int) (*models.Room, error) {
+	r := models.Room{}
+	err := s.conn.Get(&r, "SELECT * FROM room WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Room", ID)
+	}
+
+	return &r, err
+}
+
+func (s *sqliteTx) UpdateRoom(ID int, r *models.Room) error {
+	if err := checkLocation(s, r); err != nil {
+		return err
+	}
+
+	r.ID = ID
+	res, err := s.conn.NamedExec("Update room SET location=:location, name=:name, capacity=:capacity WHERE id=:id AND tenant=:tenant", s.scoped(r))
+
+	if err != nil {
+		return err
+	}
+
+	// no rows affected
+	return checkAffected(res, notFound("Room", ID))
+}
+
+func (s *sqliteTx) DeleteRoom(ID int) error {
+	if _, err := s.GetRoom(ID); err != nil {
+		return err
+	}
+	if err := checkRoomUnused(s, ID); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Exec("DELETE from room WHERE id=$1 AND tenant=$2", ID, s.tenant)
 	return err
 }
 
+/*
+	Blackout management functions
+*/
+
+func (s *sqliteTx) AddBlackout(b *models.Blackout) ([]*models.Booking, error) {
+	if err := checkBlackout(s, b); err != nil {
+		return nil, err
+	}
+	res, err := s.conn.NamedExec(
+		"INSERT INTO blackout(start_date, end_date, reason, location, class, tenant) VALUES (:start_date, :end_date, :reason, :location, :class, :tenant)",
+		s.scoped(b),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ID, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	b.ID = int(ID)
+
+	return cancelBlackedOut(s, s.clock, b)
+}
+
+func (s *sqliteTx) GetBlackouts() ([]*models.Blackout, error) {
+	blackouts := []*models.Blackout{}
+
+	err := s.conn.Select(&blackouts, `SELECT * FROM blackout WHERE tenant=$1 ORDER BY id`, s.tenant)
+
+	return blackouts, err
+}
+
+func (s *sqliteTx) GetBlackout(ID int) (*models.Blackout, error) {
+	b := models.Blackout{}
+	err := s.conn.Get(&b, "SELECT * FROM blackout WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("Blackout", ID)
+	}
+
+	return &b, err
+}
+
+func (s *sqliteTx) DeleteBlackout(ID int) error {
+	res, err := s.conn.Exec("DELETE from blackout WHERE id=$1 AND tenant=$2", ID, s.tenant)
+	if err != nil {
+		return err
+	}
+
+	// no rows affected
+	return checkAffected(res, notFound("Blackout", ID))
+}
+
 /*
 	Others
 */
 
+// scoped turns the fields of `arg` into named parameters, along with the
+// tenant the queries are scoped to
+func (s *sqliteTx) scoped(arg interface{}) map[string]interface{} {
+	params := map[string]interface{}{"tenant": s.tenant}
+	v := reflect.Indirect(reflect.ValueOf(arg))
+	for name, field := range mapper.TypeMap(v.Type()).Names {
+		// unlike FieldMap, this doesn't allocate the nil pointers of `arg`
+		params[name] = reflectx.FieldByIndexesReadOnly(v, field.Index).Interface()
+	}
+	return params
+}
+
+// migrate applies the migrations the database hasn't seen yet
+func migrate(db *sqlx.DB) error {
+	var version int
+	if err := db.Get(&version, "PRAGMA user_version"); err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		// pragmas don't support placeholders
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryBusy runs `f` again, up to a few times, when it fails because the
+// database is locked by another connection for longer than the busy timeout
+func retryBusy(f func() error) error {
+	backoff := 10 * time.Millisecond
+	for i := 0; ; i++ {
+		err := f()
+		if i == 4 || !isBusy(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// checkAffected returns `err` when the statement didn't touch any row
+func checkAffected(res sql.Result, err error) error {
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return err
+	}
+
+	return nil
+}
+
 func (s *SqliteStorage) Close() error {
+	if s.view {
+		return nil
+	}
 	return s.db.Close()
 }
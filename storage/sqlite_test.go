@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/masci/go-rest-playground/models"
+)
+
+func TestSqliteConcurrentBookings(t *testing.T) {
+	// two storages on the same file behave like two processes
+	path := filepath.Join(t.TempDir(), "gym.db")
+	stores := []Storage{
+		NewSqliteStorage(path, WithSeed(DefaultSeed())),
+		NewSqliteStorage(path),
+	}
+	defer stores[0].Close()
+	defer stores[1].Close()
+
+	// FB0001 has room for 20 people a day, book 22 on each of 5 days
+	var wg sync.WaitGroup
+	IDs := make(chan int, 110)
+	full := make(chan error, 110)
+	for i := 0; i < cap(IDs); i++ {
+		wg.Add(1)
+		go func(s Storage, i int) {
+			defer wg.Done()
+			ID, err := s.AddBooking(&models.Booking{
+				Class:    "FB0001",
+				Customer: fmt.Sprintf("Customer %d", i),
+				Date:     time.Date(2020, 1, 30+i%5, 0, 0, 0, 0, time.UTC),
+			})
+			if errors.Is(err, ErrConflict) {
+				full <- err
+				return
+			}
+			if err != nil {
+				t.Errorf("got %s", err)
+				return
+			}
+			IDs <- ID
+		}(stores[i%2], i)
+	}
+	wg.Wait()
+	close(IDs)
+	close(full)
+
+	seen := map[int]bool{}
+	for ID := range IDs {
+		if seen[ID] {
+			t.Errorf("duplicate booking id %d", ID)
+		}
+		seen[ID] = true
+	}
+	bookings, _ := stores[0].GetBookings()
+	if len(seen) != 100 || len(bookings) != 100 || len(full) != 10 {
+		t.Errorf("got %d ids, %d bookings and %d rejected, want %d, %d and %d", len(seen), len(bookings), len(full), 100, 100, 10)
+	}
+}
+
+func TestSqliteMigrations(t *testing.T) {
+	// a database created before the schema was versioned
+	path := filepath.Join(t.TempDir(), "gym.db")
+	db := sqlx.MustConnect(sqliteDriver, sqliteDSN(path))
+	db.MustExec(`
+CREATE TABLE class (id TEXT PRIMARY KEY, name TEXT, start_date DATETIME, end_date DATETIME, capacity INTEGER);
+CREATE TABLE booking (id INTEGER PRIMARY KEY, date DATETIME, customer TEXT, class TEXT);
+INSERT INTO class VALUES ('FB0001', 'Full Body', '2020-01-29 00:00:00+00:00', '2020-02-28 00:00:00+00:00', 20);
+INSERT INTO booking VALUES (1, '2020-01-30 00:00:00+00:00', 'Jane Doe', 'FB0001');
+INSERT INTO booking VALUES (2, '2020-01-30 00:00:00+00:00', 'John Doe', 'FB0001');
+`)
+	db.Close()
+
+	s := NewSqliteStorage(path)
+	defer s.Close()
+
+	// data survives the migrations
+	if b, err := s.GetBooking(2); err != nil || b.Customer != "John Doe" || b.Status != models.BookingConfirmed {
+		t.Fatalf("got %v %v", b, err)
+	}
+	if c, err := s.GetClass("FB0001"); err != nil || c.Capacity != 20 || c.Cutoff != 0 {
+		t.Fatalf("got %v %v", c, err)
+	}
+
+	// ids of deleted bookings aren't reused anymore
+	if err := s.DeleteBooking(2); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	ID, err := s.AddBooking(&models.Booking{Class: "FB0001", Customer: "Foo", Date: time.Date(2020, 1, 30, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if ID != 3 {
+		t.Errorf("got %d, want %d", ID, 3)
+	}
+}
+
+func TestSqliteTenantIndexes(t *testing.T) {
+	s := NewSqliteStorage(filepath.Join(t.TempDir(), "gym.db")).(*SqliteStorage)
+	defer s.Close()
+
+	// the queries scoped to a tenant don't scan the whole table
+	for _, table := range []string{"class", "booking", "ban", "instructor", "location", "room", "blackout"} {
+		rows, err := s.db.Query(fmt.Sprintf("EXPLAIN QUERY PLAN SELECT * FROM %s WHERE tenant=$1", table), "acme")
+		if err != nil {
+			t.Fatalf("got %s", err)
+		}
+		plan := ""
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+				t.Fatalf("got %s", err)
+			}
+			plan += detail
+		}
+		rows.Close()
+		if !strings.Contains(plan, table+"_tenant") {
+			t.Errorf("%s: got plan %q, want the %s_tenant index", table, plan, table)
+		}
+	}
+}
+
+func TestSqliteTenantsMigration(t *testing.T) {
+	// a database holding the data of a tenant before its writes were tracked
+	path := filepath.Join(t.TempDir(), "gym.db")
+	db := sqlx.MustConnect(sqliteDriver, sqliteDSN(path))
+	version := 0
+	for ; !strings.Contains(migrations[version], "adds_tenant"); version++ {
+		db.MustExec(migrations[version])
+	}
+	db.MustExec(fmt.Sprintf("PRAGMA user_version = %d", version))
+	db.MustExec("INSERT INTO instructor(name, tenant) VALUES ('Jane', 'acme')")
+	db.Close()
+
+	s := NewSqliteStorage(path)
+	defer s.Close()
+
+	if known, err := s.HasTenant("acme"); err != nil || !known {
+		t.Errorf("got %v, %v, want a known tenant", known, err)
+	}
+	if tenants, err := s.Tenants(); err != nil || len(tenants) != 2 || tenants[1] != "acme" {
+		t.Errorf("got %q, %v, want the default tenant and acme", tenants, err)
+	}
+}
@@ -1,28 +1,123 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/masci/go-rest-playground/models"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // Storage is the public API of our storage system. In this example
-// we provide two concrete implementations of this interface:
-// VolatileStorage and SqliteStorage
+// we provide three concrete implementations of this interface:
+// VolatileStorage, SqliteStorage and BoltStorage
 type Storage interface {
-	// Class
+	Tx
+
+	// WithTx runs `fn` within a transaction: the changes made through `tx`
+	// are committed when `fn` returns nil, and discarded when it returns
+	// an error, which is then returned by WithTx. `fn` must not use the
+	// storage directly, and might be called more than once when the
+	// transaction conflicts with another one, so it shouldn't have other
+	// side effects.
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
+
+	// ForTenant returns a view of the storage scoped to the tenant `ID`,
+	// which can't reach the data of any other tenant: it doesn't exist as
+	// far as the view is concerned. The storage itself is scoped to the
+	// default tenant, whose ID is empty. Closing a view is a noop.
+	//
+	// ForTenant doesn't change the storage: a tenant becomes known with
+	// its first write, or when provisioned by AddTenant.
+	ForTenant(ID string) Storage
+	// AddTenant provisions the tenant `ID`, it's a noop for known tenants
+	AddTenant(ID string) error
+	// HasTenant tells whether the tenant `ID` is known to the storage
+	HasTenant(ID string) (bool, error)
+	// Tenants lists the tenants known to the storage, the default one
+	// included
+	Tenants() ([]string, error)
+
+	// Now tells the current time according to the clock of the storage,
+	// the one its rules depend on, see WithClock
+	Now() time.Time
+
+	// Others
+	Close() error
+}
+
+// Tx groups the operations on the data, either running on their own or
+// within a transaction
+type Tx interface {
+	// Class, adding or updating a class whose instructor is teaching, or
+	// whose room is hosting, another class over the same days fails with
+	// ErrConflict
 	AddClass(*models.Class) (string, error)
 	GetClasses() ([]*models.Class, error)
 	GetClass(ID string) (*models.Class, error)
 	UpdateClass(ID string, class *models.Class) error
 	DeleteClass(ID string) error
 
-	// Booking
+	// Instructor
+	AddInstructor(*models.Instructor) (int, error)
+	GetInstructors() ([]*models.Instructor, error)
+	GetInstructor(ID int) (*models.Instructor, error)
+	UpdateInstructor(ID int, instructor *models.Instructor) error
+	// DeleteInstructor fails with ErrConflict while the instructor is
+	// assigned to some class
+	DeleteInstructor(ID int) error
+
+	// Location
+	AddLocation(*models.Location) (int, error)
+	GetLocations() ([]*models.Location, error)
+	GetLocation(ID int) (*models.Location, error)
+	UpdateLocation(ID int, location *models.Location) error
+	// DeleteLocation fails with ErrConflict while the location has rooms
+	DeleteLocation(ID int) error
+
+	// Room, the location of the room must exist
+	AddRoom(*models.Room) (int, error)
+	GetRooms() ([]*models.Room, error)
+	GetRoom(ID int) (*models.Room, error)
+	UpdateRoom(ID int, room *models.Room) error
+	// DeleteRoom fails with ErrConflict while the room hosts some class
+	DeleteRoom(ID int) error
+
+	// Booking, the class can't be booked beyond its capacity nor the one
+	// of its room
 	AddBooking(*models.Booking) (int, error)
 	GetBookings() ([]*models.Booking, error)
 	GetBooking(ID int) (*models.Booking, error)
+	// UpdateBooking moving a booking to another date, class or customer
+	// follows the rules of AddBooking, and only confirmed bookings can be
+	// moved
 	UpdateBooking(ID int, booking *models.Booking) error
 	DeleteBooking(ID int) error
+	// CancelBooking records who cancelled the booking and why, along with
+	// the current time. Cancelled bookings are kept but free their spot.
+	CancelBooking(ID int, by, reason string) (*models.Booking, error)
+	// CheckIn marks the booking as attended at the current time, which must
+	// fall within the check-in window around the booked time.
+	CheckIn(ID int) (*models.Booking, error)
+	// MarkNoShows marks the confirmed bookings whose check-in window is
+	// over as no-shows, and returns them. Customers missing too many
+	// sessions are banned, see WithNoShowPenalty.
+	MarkNoShows() ([]*models.Booking, error)
 
-	// Others
-	Close() error
+	// Ban
+	AddBan(*models.Ban) (int, error)
+	GetBans() ([]*models.Ban, error)
+	GetBan(ID int) (*models.Ban, error)
+	UpdateBan(ID int, ban *models.Ban) error
+	// LiftBan ends an active ban at the current time, recording who lifted it
+	LiftBan(ID int, by string) (*models.Ban, error)
+
+	// Blackout, classes can't be booked on the days it closes them.
+	// AddBlackout cancels the confirmed bookings falling within the
+	// blackout, free of charge, and returns them.
+	AddBlackout(*models.Blackout) ([]*models.Booking, error)
+	GetBlackouts() ([]*models.Blackout, error)
+	GetBlackout(ID int) (*models.Blackout, error)
+	// DeleteBlackout reopens the days of the blackout, the bookings it
+	// cancelled stay cancelled
+	DeleteBlackout(ID int) error
 }
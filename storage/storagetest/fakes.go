@@ -0,0 +1,62 @@
+package storagetest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeClock is a storage.Clock standing still until told otherwise
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is set at
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to the given time
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SequentialIDs is a storage.IDGenerator returning predictable identifiers,
+// numbered from 1 for every prefix, e.g. `CR0001`, `CR0002`, `YO0001`
+type SequentialIDs struct {
+	mu       sync.Mutex
+	counters map[string]int
+}
+
+// NewSequentialIDs returns a SequentialIDs generator
+func NewSequentialIDs() *SequentialIDs {
+	return &SequentialIDs{counters: map[string]int{}}
+}
+
+// NewID returns the next identifier for the prefix of the given name
+func (g *SequentialIDs) NewID(name string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	prefix := strings.ToUpper(name[:2])
+	g.counters[prefix]++
+	return fmt.Sprintf("%s%04d", prefix, g.counters[prefix])
+}
@@ -0,0 +1,1513 @@
+// The storagetest package provides a conformance test suite for the
+// implementations of the storage.Storage interface. Third-party backends
+// can run the same tests as the built-in ones:
+//
+//	func TestMyStorage(t *testing.T) {
+//		storagetest.RunConformance(t, func() storage.Storage {
+//			return NewMyStorage()
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+	"github.com/masci/go-rest-playground/storage"
+)
+
+// RunConformance runs the whole suite against the storage returned by
+// `newStorage`, which is called once for every test and must return an
+// empty storage, or one containing classes only.
+func RunConformance(t *testing.T, newStorage func() storage.Storage) {
+	tests := []struct {
+		name string
+		test func(*testing.T, storage.Storage)
+	}{
+		{"AddClass", testAddClass},
+		{"GetClass", testGetClass},
+		{"GetClasses", testGetClasses},
+		{"UpdateClass", testUpdateClass},
+		{"DeleteClass", testDeleteClass},
+		{"AddBooking", testAddBooking},
+		{"GetBooking", testGetBooking},
+		{"GetBookings", testGetBookings},
+		{"UpdateBooking", testUpdateBooking},
+		{"MoveBooking", testMoveBooking},
+		{"DeleteBooking", testDeleteBooking},
+		{"CancelBooking", testCancelBooking},
+		{"CancellationPolicy", testCancellationPolicy},
+		{"BookingWindow", testBookingWindow},
+		{"Capacity", testCapacity},
+		{"CheckIn", testCheckIn},
+		{"MarkNoShows", testMarkNoShows},
+		{"Bans", testBans},
+		{"Instructors", testInstructors},
+		{"AssignInstructor", testAssignInstructor},
+		{"Locations", testLocations},
+		{"Rooms", testRooms},
+		{"RoomCapacity", testRoomCapacity},
+		{"TimeZones", testTimeZones},
+		{"Blackouts", testBlackouts},
+		{"Tenants", testTenants},
+		{"AddTenant", testAddTenant},
+		{"WithTx", testWithTx},
+		{"Close", testClose},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage()
+			if tt.name != "Close" {
+				defer s.Close()
+			}
+			tt.test(t, s)
+		})
+	}
+}
+
+// date is a tiny helper to create dates from a human-friendly string like 2022-01-31
+func date(value string) time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// addClass adds a class available for the whole month of January 2020
+func addClass(t *testing.T, s storage.Storage, name string) *models.Class {
+	t.Helper()
+
+	c := &models.Class{
+		Name:      name,
+		StartDate: date("2020-01-01"),
+		EndDate:   date("2020-01-31"),
+		Capacity:  10,
+	}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	return c
+}
+
+// addBooking books the class on January 15th 2020
+func addBooking(t *testing.T, s storage.Storage, class, customer string) *models.Booking {
+	t.Helper()
+
+	b := &models.Booking{
+		Class:    class,
+		Customer: customer,
+		Date:     date("2020-01-15"),
+	}
+	if _, err := s.AddBooking(b); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	return b
+}
+
+func mustGetClasses(t *testing.T, s storage.Storage) []*models.Class {
+	t.Helper()
+	list, err := s.GetClasses()
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	return list
+}
+
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("got %v, want %s", err, storage.ErrNotFound)
+	}
+}
+
+/*
+	Class
+*/
+
+func testAddClass(t *testing.T, s storage.Storage) {
+	// count what's already there
+	list, _ := s.GetClasses()
+	size := len(list)
+
+	c := &models.Class{Name: "Foo"}
+	ID, err := s.AddClass(c)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if ID == "" || ID != c.ID {
+		t.Errorf("got %q, want %q", ID, c.ID)
+	}
+	if list, _ := s.GetClasses(); len(list) != size+1 {
+		t.Errorf("got %d, want %d", len(list), size+1)
+	}
+}
+
+func testGetClass(t *testing.T, s storage.Storage) {
+	want := addClass(t, s, "Pilates")
+
+	c, err := s.GetClass(want.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if c.ID != want.ID || c.Name != want.Name || c.Capacity != want.Capacity {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	if !c.StartDate.Equal(want.StartDate) || !c.EndDate.Equal(want.EndDate) {
+		t.Errorf("got %s-%s, want %s-%s", c.StartDate, c.EndDate, want.StartDate, want.EndDate)
+	}
+
+	_, err = s.GetClass("wrong id!")
+	assertNotFound(t, err)
+}
+
+func testGetClasses(t *testing.T, s storage.Storage) {
+	list, _ := s.GetClasses()
+	size := len(list)
+
+	// classes are returned in insertion order
+	names := []string{"Yoga", "Pilates", "Boxing"}
+	for _, name := range names {
+		addClass(t, s, name)
+	}
+
+	list, err := s.GetClasses()
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(list) != size+len(names) {
+		t.Fatalf("got %d, want %d", len(list), size+len(names))
+	}
+	for i, name := range names {
+		if got := list[size+i].Name; got != name {
+			t.Errorf("got %s, want %s", got, name)
+		}
+	}
+}
+
+func testUpdateClass(t *testing.T, s storage.Storage) {
+	// wrong input
+	err := s.UpdateClass("wrong id!", &models.Class{})
+	assertNotFound(t, err)
+
+	// input ok
+	c := addClass(t, s, "Pilates")
+	newName := "Pilates Plus"
+	err = s.UpdateClass(c.ID, &models.Class{
+		Name:      newName,
+		StartDate: c.StartDate,
+		EndDate:   c.EndDate,
+		Capacity:  20,
+	})
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// get it again
+	c, _ = s.GetClass(c.ID)
+	if c.Name != newName || c.Capacity != 20 {
+		t.Errorf("got %s %d, want %s %d", c.Name, c.Capacity, newName, 20)
+	}
+}
+
+func testDeleteClass(t *testing.T, s storage.Storage) {
+	c := addClass(t, s, "Pilates")
+
+	err := s.DeleteClass(c.ID)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	// ensure isn't there anymore
+	_, err = s.GetClass(c.ID)
+	assertNotFound(t, err)
+	for _, item := range mustGetClasses(t, s) {
+		if item.ID == c.ID {
+			t.Errorf("class %s still listed", c.ID)
+		}
+	}
+
+	err = s.DeleteClass(c.ID)
+	assertNotFound(t, err)
+}
+
+/*
+	Booking
+*/
+
+func testAddBooking(t *testing.T, s storage.Storage) {
+	c := addClass(t, s, "Pilates")
+
+	// missing class id
+	_, err := s.AddBooking(&models.Booking{})
+	if err == nil {
+		t.Errorf("got nil, want error")
+	}
+	// unknown class id
+	_, err = s.AddBooking(&models.Booking{Class: "wrong id!", Date: date("2020-01-15")})
+	assertNotFound(t, err)
+	// missing booking date
+	_, err = s.AddBooking(&models.Booking{Class: c.ID})
+	if err == nil {
+		t.Errorf("got nil, want error")
+	}
+	// class not available at that date, the error tells when it is
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Date: date("2020-02-01")})
+	if err == nil || !strings.Contains(err.Error(), "from 2020-01-01 to 2020-01-31") {
+		t.Errorf("got %v, want an error naming the days of the class", err)
+	}
+	if list, _ := s.GetBookings(); len(list) != 0 {
+		t.Errorf("got %d, want %d", len(list), 0)
+	}
+
+	// the first and the last day are included
+	for _, day := range []time.Time{date("2020-01-01"), date("2020-01-31").Add(23*time.Hour + 59*time.Minute)} {
+		if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: day}); err != nil {
+			t.Errorf("%s: got %s", day, err)
+		}
+	}
+
+	// input ok
+	b := &models.Booking{Class: c.ID, Date: date("2020-01-15")}
+	ID, err := s.AddBooking(b)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	if ID < 1 || ID != b.ID {
+		t.Errorf("got %d, want %d", ID, b.ID)
+	}
+	if b, _ := s.GetBooking(ID); b == nil || b.Status != models.BookingConfirmed {
+		t.Errorf("got %+v, want status %s", b, models.BookingConfirmed)
+	}
+}
+
+func testGetBooking(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.GetBooking(-1)
+	assertNotFound(t, err)
+
+	c := addClass(t, s, "Pilates")
+	want := addBooking(t, s, c.ID, "Foo")
+
+	// input ok
+	b, err := s.GetBooking(want.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if b.ID != want.ID || b.Class != c.ID || b.Customer != "Foo" || !b.Date.Equal(want.Date) {
+		t.Errorf("got %+v, want %+v", b, want)
+	}
+}
+
+func testGetBookings(t *testing.T, s storage.Storage) {
+	pilates := addClass(t, s, "Pilates")
+	dance := addClass(t, s, "Dance")
+
+	// bookings are returned sorted by ID
+	want := []*models.Booking{}
+	for i := 0; i < 5; i++ {
+		class := pilates.ID
+		if i%2 == 0 {
+			class = dance.ID
+		}
+		want = append(want, addBooking(t, s, class, "Foo"))
+	}
+
+	bookings, err := s.GetBookings()
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(bookings) != len(want) {
+		t.Fatalf("got %d, want %d", len(bookings), len(want))
+	}
+	for i, b := range bookings {
+		if b.ID != want[i].ID {
+			t.Errorf("got %d, want %d", b.ID, want[i].ID)
+		}
+		if i > 0 && b.ID <= bookings[i-1].ID {
+			t.Errorf("booking %d listed after %d", b.ID, bookings[i-1].ID)
+		}
+	}
+}
+
+func testUpdateBooking(t *testing.T, s storage.Storage) {
+	// test invalid input
+	err := s.UpdateBooking(-1, &models.Booking{})
+	assertNotFound(t, err)
+
+	c := addClass(t, s, "Pilates")
+	b := addBooking(t, s, c.ID, "Foo")
+
+	// update the Customer field
+	err = s.UpdateBooking(b.ID, &models.Booking{
+		Customer: "Bar",
+		Class:    b.Class,
+		Date:     b.Date,
+	})
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// reload to assert record was updated
+	newb, _ := s.GetBooking(b.ID)
+	if newb.Customer != "Bar" {
+		t.Errorf("got %s, want %s", newb.Customer, "Bar")
+	}
+}
+
+func testMoveBooking(t *testing.T, s storage.Storage) {
+	c := &models.Class{Name: "Boxing", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Capacity: 2}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	first := addBooking(t, s, c.ID, "Foo")
+	addBooking(t, s, c.ID, "Bar")
+	b := &models.Booking{Class: c.ID, Customer: "Baz", Date: date("2020-01-16")}
+	if _, err := s.AddBooking(b); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// the class is full on the 15th, and isn't available in February
+	moved := *b
+	moved.Date = date("2020-01-15")
+	if err := s.UpdateBooking(b.ID, &moved); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	moved.Date = date("2020-02-01")
+	if err := s.UpdateBooking(b.ID, &moved); err == nil {
+		t.Errorf("got no error, want one for a day the class isn't available")
+	}
+	if stored, _ := s.GetBooking(b.ID); !stored.Date.Equal(b.Date) {
+		t.Errorf("got %s, want %s", stored.Date, b.Date)
+	}
+
+	// a booking doesn't take its own spot when moved within the day
+	moved = *first
+	moved.Date = date("2020-01-15").Add(18 * time.Hour)
+	if err := s.UpdateBooking(first.ID, &moved); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// cancelled bookings can't be moved
+	if _, err := s.CancelBooking(first.ID, "Foo", ""); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	cancelled, _ := s.GetBooking(first.ID)
+	cancelled.Date = date("2020-01-20")
+	if err := s.UpdateBooking(first.ID, cancelled); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+}
+
+func testDeleteBooking(t *testing.T, s storage.Storage) {
+	c := addClass(t, s, "Pilates")
+	b := addBooking(t, s, c.ID, "Foo")
+
+	err := s.DeleteBooking(b.ID)
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+	_, err = s.GetBooking(b.ID)
+	assertNotFound(t, err)
+
+	err = s.DeleteBooking(b.ID)
+	assertNotFound(t, err)
+
+	// the identifier of the deleted booking isn't reused
+	if next := addBooking(t, s, c.ID, "Bar"); next.ID <= b.ID {
+		t.Errorf("got %d, want more than %d", next.ID, b.ID)
+	}
+}
+
+func testCancelBooking(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.CancelBooking(-1, "Foo", "")
+	assertNotFound(t, err)
+
+	c := addClass(t, s, "Pilates")
+	b := addBooking(t, s, c.ID, "Foo")
+
+	cancelled, err := s.CancelBooking(b.ID, "Bar", "sick")
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if cancelled.Status != models.BookingCancelled || cancelled.CancelledBy != "Bar" ||
+		cancelled.CancelReason != "sick" || cancelled.CancelledAt == nil {
+		t.Errorf("got %+v", cancelled)
+	}
+
+	// the booking is still there
+	stored, err := s.GetBooking(b.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.Status != models.BookingCancelled || stored.CancelledAt == nil || !stored.CancelledAt.Equal(*cancelled.CancelledAt) {
+		t.Errorf("got %+v, want %+v", stored, cancelled)
+	}
+
+	// but can't be cancelled twice
+	_, err = s.CancelBooking(b.ID, "Bar", "")
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+}
+
+func testCancellationPolicy(t *testing.T, s storage.Storage) {
+	// the storages run on the real clock, so January 2020 is long gone
+	// and January 2100 is far enough
+	policy := models.CancellationPolicy{Cutoff: models.Duration(12 * time.Hour), Fee: 500, ForfeitCredit: true}
+	past := &models.Class{Name: "Boxing", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), CancellationPolicy: policy}
+	future := &models.Class{Name: "Boxing", StartDate: date("2100-01-01"), EndDate: date("2100-01-31"), CancellationPolicy: policy}
+	for _, c := range []*models.Class{past, future} {
+		if _, err := s.AddClass(c); err != nil {
+			t.Fatalf("got %s", err)
+		}
+	}
+
+	// the policy is stored with the class
+	stored, err := s.GetClass(past.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.CancellationPolicy != policy {
+		t.Errorf("got %+v, want %+v", stored.CancellationPolicy, policy)
+	}
+
+	// cancelling after the cutoff costs the fee
+	b := addBooking(t, s, past.ID, "Foo")
+	late, err := s.CancelBooking(b.ID, "Foo", "")
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if late.Cancellation != models.CancelLate || late.CancelFee != 500 || !late.CreditForfeited {
+		t.Errorf("got %+v", late)
+	}
+	if stored, _ := s.GetBooking(b.ID); stored.Cancellation != models.CancelLate || stored.CancelFee != 500 || !stored.CreditForfeited {
+		t.Errorf("got %+v, want %+v", stored, late)
+	}
+
+	// before the cutoff it's free
+	b = &models.Booking{Class: future.ID, Customer: "Foo", Date: date("2100-01-15")}
+	if _, err := s.AddBooking(b); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	free, err := s.CancelBooking(b.ID, "Foo", "")
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if free.Cancellation != models.CancelFree || free.CancelFee != 0 || free.CreditForfeited {
+		t.Errorf("got %+v", free)
+	}
+
+	// and so it is when the class has no policy
+	c := addClass(t, s, "Pilates")
+	b = addBooking(t, s, c.ID, "Foo")
+	if free, _ := s.CancelBooking(b.ID, "Foo", ""); free.Cancellation != models.CancelFree {
+		t.Errorf("got %s, want %s", free.Cancellation, models.CancelFree)
+	}
+}
+
+func testBookingWindow(t *testing.T, s storage.Storage) {
+	// the window must be open for a while
+	window := models.BookingWindow{OpensDays: 1, ClosesMinutes: 24 * 60}
+	if _, err := s.AddClass(&models.Class{Name: "Boxing", BookingWindow: window}); err == nil {
+		t.Errorf("got no error, want one for a window that's never open")
+	}
+	window = models.BookingWindow{OpensDays: -1}
+	if _, err := s.AddClass(&models.Class{Name: "Boxing", BookingWindow: window}); err == nil {
+		t.Errorf("got no error, want one for a negative window")
+	}
+
+	// the storages run on the real clock, the class runs from yesterday on
+	now := time.Now().UTC().Truncate(time.Second)
+	window = models.BookingWindow{OpensDays: 7, ClosesMinutes: 60}
+	c := &models.Class{Name: "Boxing", StartDate: now.AddDate(0, 0, -1), EndDate: now.AddDate(0, 1, 0), BookingWindow: window}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// the window is stored with the class
+	stored, err := s.GetClass(c.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.BookingWindow != window {
+		t.Errorf("got %+v, want %+v", stored.BookingWindow, window)
+	}
+
+	tests := []struct {
+		date time.Time
+		want error
+	}{
+		{now.Add(30 * time.Minute), storage.ErrConflict},
+		{now.Add(2 * time.Hour), nil},
+		{now.AddDate(0, 0, 6), nil},
+		{now.AddDate(0, 0, 8), storage.ErrConflict},
+	}
+	for _, tt := range tests {
+		_, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: tt.date})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("booking for %s: got %v, want %v", tt.date, err, tt.want)
+		}
+	}
+
+	// bookings can't be moved out of the window either
+	b := &models.Booking{Class: c.ID, Customer: "Bar", Date: now.AddDate(0, 0, 3)}
+	if _, err := s.AddBooking(b); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	for _, tt := range tests {
+		moved := *b
+		moved.Date = tt.date
+		if err := s.UpdateBooking(b.ID, &moved); !errors.Is(err, tt.want) {
+			t.Errorf("moving to %s: got %v, want %v", tt.date, err, tt.want)
+		}
+	}
+	if got, _ := s.GetBooking(b.ID); got == nil || !got.Date.Equal(now.AddDate(0, 0, 6)) {
+		t.Errorf("got %+v, want the last date within the window", got)
+	}
+
+	// without limits the class can be booked at any time
+	stored.BookingWindow = models.BookingWindow{}
+	if err := s.UpdateClass(c.ID, stored); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: now.AddDate(0, 0, 8)}); err != nil {
+		t.Errorf("got %s", err)
+	}
+}
+
+func testCapacity(t *testing.T, s storage.Storage) {
+	c := &models.Class{
+		Name:      "Boxing",
+		StartDate: date("2020-01-01"),
+		EndDate:   date("2020-01-31"),
+		Capacity:  2,
+	}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	first := addBooking(t, s, c.ID, "Foo")
+	addBooking(t, s, c.ID, "Bar")
+
+	// the class is full on the 15th
+	_, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Baz", Date: date("2020-01-15")})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	// but not the day after
+	if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Baz", Date: date("2020-01-16")}); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// cancelled bookings free their spot
+	if _, err := s.CancelBooking(first.ID, "Foo", ""); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	addBooking(t, s, c.ID, "Baz")
+}
+
+// addSessionNow books a class taking place right now, the storages run on
+// the real clock
+func addSessionNow(t *testing.T, s storage.Storage, customer string) *models.Booking {
+	t.Helper()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &models.Class{Name: "Spinning", StartDate: now.Add(-24 * time.Hour), EndDate: now.Add(24 * time.Hour)}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	b := &models.Booking{Class: c.ID, Customer: customer, Date: now}
+	if _, err := s.AddBooking(b); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	return b
+}
+
+func testCheckIn(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.CheckIn(-1)
+	assertNotFound(t, err)
+
+	// the check-in window of a past session is closed
+	c := addClass(t, s, "Pilates")
+	past := addBooking(t, s, c.ID, "Foo")
+	if _, err := s.CheckIn(past.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+
+	b := addSessionNow(t, s, "Foo")
+	attended, err := s.CheckIn(b.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if attended.Status != models.BookingAttended || attended.CheckedInAt == nil {
+		t.Errorf("got %+v", attended)
+	}
+
+	// the check-in time is stored
+	stored, err := s.GetBooking(b.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.Status != models.BookingAttended || stored.CheckedInAt == nil || !stored.CheckedInAt.Equal(*attended.CheckedInAt) {
+		t.Errorf("got %+v, want %+v", stored, attended)
+	}
+
+	// but checking in twice isn't allowed
+	if _, err := s.CheckIn(b.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+}
+
+func testMarkNoShows(t *testing.T, s storage.Storage) {
+	c := addClass(t, s, "Pilates")
+	missed := addBooking(t, s, c.ID, "Foo")
+	cancelled := addBooking(t, s, c.ID, "Bar")
+	if _, err := s.CancelBooking(cancelled.ID, "Bar", ""); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	ongoing := addSessionNow(t, s, "Baz")
+
+	// only the confirmed bookings of past sessions are marked
+	marked, err := s.MarkNoShows()
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(marked) != 1 || marked[0].ID != missed.ID {
+		t.Errorf("got %+v, want booking %d only", marked, missed.ID)
+	}
+	for ID, want := range map[int]string{
+		missed.ID:    models.BookingNoShow,
+		cancelled.ID: models.BookingCancelled,
+		ongoing.ID:   models.BookingConfirmed,
+	} {
+		if b, _ := s.GetBooking(ID); b.Status != want {
+			t.Errorf("got %s, want %s", b.Status, want)
+		}
+	}
+
+	// marking again is harmless
+	if marked, _ := s.MarkNoShows(); len(marked) != 0 {
+		t.Errorf("got %d, want %d", len(marked), 0)
+	}
+}
+
+/*
+	Bans
+*/
+
+func testBans(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.GetBan(-1)
+	assertNotFound(t, err)
+	_, err = s.LiftBan(-1, "Staff")
+	assertNotFound(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	ban := &models.Ban{Customer: "Foo", Reason: "3 no-shows", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if _, err := s.AddBan(ban); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	stored, err := s.GetBan(ban.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.Customer != "Foo" || !stored.ExpiresAt.Equal(ban.ExpiresAt) || stored.LiftedAt != nil {
+		t.Errorf("got %+v, want %+v", stored, ban)
+	}
+	// expired bans are kept but don't matter
+	expired := &models.Ban{Customer: "Bar", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}
+	if _, err := s.AddBan(expired); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if bans, _ := s.GetBans(); len(bans) != 2 {
+		t.Errorf("got %d, want %d", len(bans), 2)
+	}
+
+	// banned customers can't book
+	c := addClass(t, s, "Pilates")
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: date("2020-01-15")})
+	banned := &storage.BannedError{}
+	if !errors.As(err, &banned) || !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("got %v, want a BannedError", err)
+	}
+	if banned.Ban.ID != ban.ID {
+		t.Errorf("got %d, want %d", banned.Ban.ID, ban.ID)
+	}
+	addBooking(t, s, c.ID, "Bar")
+
+	// until the ban is lifted
+	lifted, err := s.LiftBan(ban.ID, "Staff")
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if lifted.LiftedBy != "Staff" || lifted.LiftedAt == nil {
+		t.Errorf("got %+v", lifted)
+	}
+	if stored, _ := s.GetBan(ban.ID); stored.LiftedBy != "Staff" || stored.LiftedAt == nil {
+		t.Errorf("got %+v, want %+v", stored, lifted)
+	}
+	if _, err := s.LiftBan(ban.ID, "Staff"); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	if _, err := s.LiftBan(expired.ID, "Staff"); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	addBooking(t, s, c.ID, "Foo")
+}
+
+/*
+	Instructors
+*/
+
+func testInstructors(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.GetInstructor(-1)
+	assertNotFound(t, err)
+	assertNotFound(t, s.UpdateInstructor(-1, &models.Instructor{Name: "Foo"}))
+	assertNotFound(t, s.DeleteInstructor(-1))
+
+	i := &models.Instructor{Name: "Jane", Email: "jane@example.com"}
+	if _, err := s.AddInstructor(i); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	other := &models.Instructor{Name: "John"}
+	if _, err := s.AddInstructor(other); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if i.ID == other.ID {
+		t.Errorf("got the same id %d twice", i.ID)
+	}
+	stored, err := s.GetInstructor(i.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if *stored != *i {
+		t.Errorf("got %+v, want %+v", stored, i)
+	}
+	if instructors, _ := s.GetInstructors(); len(instructors) != 2 || instructors[0].ID != i.ID {
+		t.Errorf("got %+v", instructors)
+	}
+
+	i.Email = "jane@example.org"
+	if err := s.UpdateInstructor(i.ID, i); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored, _ := s.GetInstructor(i.ID); stored.Email != i.Email {
+		t.Errorf("got %s, want %s", stored.Email, i.Email)
+	}
+
+	if err := s.DeleteInstructor(other.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	_, err = s.GetInstructor(other.ID)
+	assertNotFound(t, err)
+}
+
+func testAssignInstructor(t *testing.T, s storage.Storage) {
+	i := &models.Instructor{Name: "Jane"}
+	if _, err := s.AddInstructor(i); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// unknown instructors can't teach
+	c := &models.Class{Name: "Pilates", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Instructor: i.ID + 1}
+	_, err := s.AddClass(c)
+	assertNotFound(t, err)
+
+	c.Instructor = i.ID
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored, _ := s.GetClass(c.ID); stored.Instructor != i.ID {
+		t.Errorf("got %d, want %d", stored.Instructor, i.ID)
+	}
+	// updating the class doesn't clash with itself
+	c.Capacity = 5
+	if err := s.UpdateClass(c.ID, c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// the instructor can't teach overlapping classes, the last day included
+	overlapping := &models.Class{Name: "Yoga", StartDate: date("2020-01-31"), EndDate: date("2020-02-29"), Instructor: i.ID}
+	if _, err := s.AddClass(overlapping); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	later := &models.Class{Name: "Yoga", StartDate: date("2020-02-01"), EndDate: date("2020-02-29"), Instructor: i.ID}
+	if _, err := s.AddClass(later); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	later.StartDate = date("2020-01-15")
+	if err := s.UpdateClass(later.ID, later); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	if stored, _ := s.GetClass(later.ID); !stored.StartDate.Equal(date("2020-02-01")) {
+		t.Errorf("got %s, want %s", stored.StartDate, date("2020-02-01"))
+	}
+
+	// sessions on the same days clash only when their times overlap
+	hour := models.Duration(time.Hour)
+	morning := &models.Class{Name: "Yoga", StartDate: date("2020-03-02"), EndDate: date("2020-03-31"), StartTime: "09:00", Duration: hour, Instructor: i.ID}
+	if _, err := s.AddClass(morning); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	evening := &models.Class{Name: "Boxing", StartDate: date("2020-03-02"), EndDate: date("2020-03-31"), StartTime: "18:00", Duration: hour, Instructor: i.ID}
+	if _, err := s.AddClass(evening); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored, _ := s.GetClass(evening.ID); stored == nil || stored.StartTime != "18:00" || stored.Duration != hour {
+		t.Errorf("got %+v, want sessions at 18:00 for %s", stored, time.Duration(hour))
+	}
+	clashing := []*models.Class{
+		{StartDate: date("2020-03-10"), EndDate: date("2020-03-10"), StartTime: "09:30", Duration: hour},
+		// classes without a duration take up the whole day
+		{StartDate: date("2020-03-10"), EndDate: date("2020-03-10")},
+		// sessions can run past midnight
+		{StartDate: date("2020-03-01"), EndDate: date("2020-03-01"), StartTime: "23:30", Duration: models.Duration(10 * time.Hour)},
+		// 10:30 in Rome is 09:30 UTC
+		{StartDate: date("2020-03-10"), EndDate: date("2020-03-10"), StartTime: "10:30", Duration: hour, TimeZone: "Europe/Rome"},
+	}
+	for _, other := range clashing {
+		other.Name, other.Instructor = "Spinning", i.ID
+		if _, err := s.AddClass(other); !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("%+v: got %v, want %s", other, err, storage.ErrConflict)
+		}
+	}
+
+	// sessions start at a time of day and last up to a day
+	invalid := []*models.Class{
+		{StartTime: "25:00", Duration: hour},
+		{StartTime: "09:00"},
+		{Duration: hour},
+		{StartTime: "09:00", Duration: models.Duration(25 * time.Hour)},
+	}
+	for _, other := range invalid {
+		other.Name, other.StartDate, other.EndDate = "Spinning", date("2020-04-01"), date("2020-04-30")
+		if _, err := s.AddClass(other); err == nil || errors.Is(err, storage.ErrConflict) {
+			t.Errorf("%+v: got %v, want a validation error", other, err)
+		}
+	}
+
+	// instructors teaching a class can't be deleted
+	if err := s.DeleteInstructor(i.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	for _, ID := range []string{c.ID, later.ID, morning.ID, evening.ID} {
+		if err := s.DeleteClass(ID); err != nil {
+			t.Fatalf("got %s", err)
+		}
+	}
+	if err := s.DeleteInstructor(i.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+}
+
+/*
+	Locations and rooms
+*/
+
+// addRoom adds a location with a room of the given capacity
+func addRoom(t *testing.T, s storage.Storage, capacity int) *models.Room {
+	t.Helper()
+
+	l := &models.Location{Name: "Downtown"}
+	if _, err := s.AddLocation(l); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	room := &models.Room{Location: l.ID, Name: "Studio 1", Capacity: capacity}
+	if _, err := s.AddRoom(room); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	return room
+}
+
+func testLocations(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.GetLocation(-1)
+	assertNotFound(t, err)
+	assertNotFound(t, s.UpdateLocation(-1, &models.Location{Name: "Foo"}))
+	assertNotFound(t, s.DeleteLocation(-1))
+
+	l := &models.Location{Name: "Downtown", Address: "1 Main St"}
+	if _, err := s.AddLocation(l); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	stored, err := s.GetLocation(l.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if *stored != *l {
+		t.Errorf("got %+v, want %+v", stored, l)
+	}
+
+	l.Address = "2 Main St"
+	if err := s.UpdateLocation(l.ID, l); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if locations, _ := s.GetLocations(); len(locations) != 1 || locations[0].Address != l.Address {
+		t.Errorf("got %+v, want %+v", locations, l)
+	}
+
+	// locations with rooms can't be deleted
+	room := &models.Room{Location: l.ID, Name: "Studio 1"}
+	if _, err := s.AddRoom(room); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if err := s.DeleteLocation(l.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	if err := s.DeleteRoom(room.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if err := s.DeleteLocation(l.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	_, err = s.GetLocation(l.ID)
+	assertNotFound(t, err)
+}
+
+func testRooms(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.GetRoom(-1)
+	assertNotFound(t, err)
+	assertNotFound(t, s.DeleteRoom(-1))
+
+	// rooms belong to an existing location
+	_, err = s.AddRoom(&models.Room{Location: 42, Name: "Studio 1"})
+	assertNotFound(t, err)
+
+	room := addRoom(t, s, 20)
+	stored, err := s.GetRoom(room.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if *stored != *room {
+		t.Errorf("got %+v, want %+v", stored, room)
+	}
+	room.Capacity = 15
+	if err := s.UpdateRoom(room.ID, room); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if rooms, _ := s.GetRooms(); len(rooms) != 1 || rooms[0].Capacity != 15 {
+		t.Errorf("got %+v, want %+v", rooms, room)
+	}
+
+	// unknown rooms can't host classes
+	c := &models.Class{Name: "Pilates", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Room: room.ID + 1}
+	_, err = s.AddClass(c)
+	assertNotFound(t, err)
+
+	// the room hosts one class at a time
+	c.Room = room.ID
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if err := s.UpdateClass(c.ID, c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	overlapping := &models.Class{Name: "Yoga", StartDate: date("2020-01-31"), EndDate: date("2020-02-29"), Room: room.ID}
+	if _, err := s.AddClass(overlapping); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	other := addRoom(t, s, 0)
+	overlapping.Room = other.ID
+	if _, err := s.AddClass(overlapping); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	overlapping.Room = room.ID
+	if err := s.UpdateClass(overlapping.ID, overlapping); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+
+	// timed sessions can follow each other back to back
+	hour := models.Duration(time.Hour)
+	first := &models.Class{Name: "Boxing", StartDate: date("2020-03-01"), EndDate: date("2020-03-31"), StartTime: "09:00", Duration: hour, Room: room.ID}
+	second := &models.Class{Name: "Spinning", StartDate: date("2020-03-01"), EndDate: date("2020-03-31"), StartTime: "10:00", Duration: hour, Room: room.ID}
+	for _, class := range []*models.Class{first, second} {
+		if _, err := s.AddClass(class); err != nil {
+			t.Fatalf("got %s", err)
+		}
+	}
+	late := &models.Class{Name: "Zumba", StartDate: date("2020-03-15"), EndDate: date("2020-04-15"), StartTime: "10:30", Duration: hour, Room: room.ID}
+	if _, err := s.AddClass(late); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	late.StartTime = "11:00"
+	if _, err := s.AddClass(late); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// rooms hosting a class can't be deleted
+	if err := s.DeleteRoom(room.ID); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	for _, ID := range []string{c.ID, first.ID, second.ID, late.ID} {
+		if err := s.DeleteClass(ID); err != nil {
+			t.Fatalf("got %s", err)
+		}
+	}
+	if err := s.DeleteRoom(room.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+}
+
+func testRoomCapacity(t *testing.T, s storage.Storage) {
+	// the room is smaller than the class
+	room := addRoom(t, s, 1)
+	c := &models.Class{Name: "Pilates", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Capacity: 10, Room: room.ID}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	addBooking(t, s, c.ID, "Foo")
+	_, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Bar", Date: date("2020-01-15")})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+
+	// the class is smaller than the room, or has no limit
+	room.Capacity = 10
+	if err := s.UpdateRoom(room.ID, room); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	c.Capacity = 2
+	if err := s.UpdateClass(c.ID, c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	addBooking(t, s, c.ID, "Bar")
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Customer: "Baz", Date: date("2020-01-15")})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	c.Capacity = 0
+	if err := s.UpdateClass(c.ID, c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	addBooking(t, s, c.ID, "Baz")
+}
+
+/*
+	Time zones
+*/
+
+func testTimeZones(t *testing.T, s storage.Storage) {
+	// unknown time zones are rejected
+	_, err := s.AddClass(&models.Class{Name: "Pilates", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), TimeZone: "Mars/Olympus"})
+	if err == nil {
+		t.Error("got no error, want one for the unknown time zone")
+	}
+	_, err = s.AddLocation(&models.Location{Name: "Downtown", TimeZone: "Mars/Olympus"})
+	if err == nil {
+		t.Error("got no error, want one for the unknown time zone")
+	}
+
+	// summer time in Rome started on March 29th 2020
+	c := &models.Class{Name: "Pilates", StartDate: date("2020-03-29"), EndDate: date("2020-10-25"), Capacity: 1, TimeZone: "Europe/Rome"}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	stored, err := s.GetClass(c.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.TimeZone != c.TimeZone {
+		t.Errorf("got %s, want %s", stored.TimeZone, c.TimeZone)
+	}
+
+	// the class starts at midnight in Rome, 11pm of the day before in UTC
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: time.Date(2020, 3, 28, 22, 30, 0, 0, time.UTC)})
+	if err == nil {
+		t.Error("got no error, want one for the booking before the class")
+	}
+	if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: time.Date(2020, 3, 28, 23, 30, 0, 0, time.UTC)}); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// the capacity is per day in Rome: 11:30pm and 12:30am are on different days
+	// in summer time, despite being on the same day in UTC
+	if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: time.Date(2020, 3, 30, 21, 30, 0, 0, time.UTC)}); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Bar", Date: time.Date(2020, 3, 30, 22, 30, 0, 0, time.UTC)}); err != nil {
+		t.Errorf("got %s", err)
+	}
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Customer: "Baz", Date: time.Date(2020, 3, 30, 5, 0, 0, 0, time.UTC)})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+
+	// classes without a time zone take the one of their location
+	room := addRoom(t, s, 0)
+	l, err := s.GetLocation(room.Location)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	l.TimeZone = "America/New_York"
+	if err := s.UpdateLocation(l.ID, l); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	c = &models.Class{Name: "Yoga", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Room: room.ID}
+	if _, err := s.AddClass(c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)})
+	if err == nil {
+		t.Error("got no error, want one for the booking before the class")
+	}
+	if _, err := s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: time.Date(2020, 1, 31, 3, 0, 0, 0, time.UTC)}); err != nil {
+		t.Errorf("got %s", err)
+	}
+}
+
+/*
+	Blackouts
+*/
+
+func testBlackouts(t *testing.T, s storage.Storage) {
+	// wrong id
+	_, err := s.GetBlackout(-1)
+	assertNotFound(t, err)
+	assertNotFound(t, s.DeleteBlackout(-1))
+
+	c := addClass(t, s, "Pilates")
+	c.CancellationPolicy = models.CancellationPolicy{Cutoff: models.Duration(time.Hour), Fee: 500}
+	if err := s.UpdateClass(c.ID, c); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	room := addRoom(t, s, 0)
+	other := &models.Class{Name: "Yoga", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Room: room.ID}
+	if _, err := s.AddClass(other); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// invalid blackouts
+	invalid := []*models.Blackout{
+		{StartDate: date("2020-01-15"), EndDate: date("2020-01-14")},
+		{StartDate: date("2020-01-15"), EndDate: date("2020-01-15"), Class: c.ID, Location: room.Location},
+	}
+	for _, b := range invalid {
+		if _, err := s.AddBlackout(b); err == nil {
+			t.Errorf("%+v: got no error, want one", b)
+		}
+	}
+	_, err = s.AddBlackout(&models.Blackout{StartDate: date("2020-01-15"), EndDate: date("2020-01-15"), Class: "wrong id!"})
+	assertNotFound(t, err)
+	_, err = s.AddBlackout(&models.Blackout{StartDate: date("2020-01-15"), EndDate: date("2020-01-15"), Location: 42})
+	assertNotFound(t, err)
+
+	// blackouts of a class cancel its bookings within the blackout only, for free
+	foo := addBooking(t, s, c.ID, "Foo")
+	bar := addBooking(t, s, other.ID, "Bar")
+	later := &models.Booking{Class: c.ID, Customer: "Baz", Date: date("2020-01-20")}
+	if _, err := s.AddBooking(later); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	classBlackout := &models.Blackout{StartDate: date("2020-01-14"), EndDate: date("2020-01-16"), Reason: "maintenance", Class: c.ID}
+	cancelled, err := s.AddBlackout(classBlackout)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != foo.ID {
+		t.Fatalf("got %+v, want booking %d", cancelled, foo.ID)
+	}
+	stored, err := s.GetBooking(foo.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.Status != models.BookingCancelled || stored.Cancellation != models.CancelFree || stored.CancelFee != 0 || stored.CancelReason != "maintenance" {
+		t.Errorf("got %+v, want a free cancellation for maintenance", stored)
+	}
+	for _, ID := range []int{bar.ID, later.ID} {
+		if b, _ := s.GetBooking(ID); b == nil || b.Status != models.BookingConfirmed {
+			t.Errorf("got %+v, want status %s", b, models.BookingConfirmed)
+		}
+	}
+
+	// the days of the blackout can't be booked
+	_, err = s.AddBooking(&models.Booking{Class: c.ID, Customer: "Foo", Date: date("2020-01-16").Add(23 * time.Hour)})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	addBooking(t, s, other.ID, "Foo")
+
+	// nor can bookings be moved there
+	moved := *later
+	moved.Date = date("2020-01-15")
+	if err := s.UpdateBooking(later.ID, &moved); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("got %v, want %s", err, storage.ErrConflict)
+	}
+	if b, _ := s.GetBooking(later.ID); b == nil || !b.Date.Equal(later.Date) {
+		t.Errorf("got %+v, want date %s", b, later.Date)
+	}
+
+	// blackouts of a location close the classes hosted there, global ones close everything
+	cancelled, err = s.AddBlackout(&models.Blackout{StartDate: date("2020-01-15"), EndDate: date("2020-01-15"), Location: room.Location})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(cancelled) != 2 || cancelled[0].ID != bar.ID || cancelled[0].CancelReason == "" {
+		t.Errorf("got %+v, want the bookings of class %s", cancelled, other.ID)
+	}
+	cancelled, err = s.AddBlackout(&models.Blackout{StartDate: date("2020-01-20"), EndDate: date("2020-01-20"), Reason: "holiday"})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != later.ID {
+		t.Errorf("got %+v, want booking %d", cancelled, later.ID)
+	}
+	blackouts, err := s.GetBlackouts()
+	if err != nil || len(blackouts) != 3 {
+		t.Fatalf("got %d blackouts %v, want %d", len(blackouts), err, 3)
+	}
+	if b := blackouts[0]; b.ID != classBlackout.ID || b.Class != classBlackout.Class || b.Reason != classBlackout.Reason ||
+		!b.StartDate.Equal(classBlackout.StartDate) || !b.EndDate.Equal(classBlackout.EndDate) {
+		t.Errorf("got %+v, want %+v", b, classBlackout)
+	}
+
+	// deleting the blackout reopens its days, cancelled bookings stay cancelled
+	if err := s.DeleteBlackout(classBlackout.ID); err != nil {
+		t.Fatalf("got %s", err)
+	}
+	_, err = s.GetBlackout(classBlackout.ID)
+	assertNotFound(t, err)
+	addBooking(t, s, c.ID, "Foo")
+	if b, _ := s.GetBooking(foo.ID); b == nil || b.Status != models.BookingCancelled {
+		t.Errorf("got %+v, want status %s", b, models.BookingCancelled)
+	}
+}
+
+/*
+	Tenants
+*/
+
+func testTenants(t *testing.T, s storage.Storage) {
+	a, b := s.ForTenant("a"), s.ForTenant("b")
+	c := addClass(t, a, "Pilates")
+	booking := addBooking(t, a, c.ID, "Foo")
+	room := addRoom(t, a, 0)
+	instructor := &models.Instructor{Name: "Jane"}
+	if _, err := a.AddInstructor(instructor); err != nil {
+		t.Fatalf("got %s", err)
+	}
+
+	// other tenants can't read the data of `a`
+	_, err := b.GetClass(c.ID)
+	assertNotFound(t, err)
+	_, err = s.GetClass(c.ID)
+	assertNotFound(t, err)
+	_, err = b.GetBooking(booking.ID)
+	assertNotFound(t, err)
+	_, err = b.GetRoom(room.ID)
+	assertNotFound(t, err)
+	_, err = b.GetLocation(room.Location)
+	assertNotFound(t, err)
+	_, err = b.GetInstructor(instructor.ID)
+	assertNotFound(t, err)
+	if classes := mustGetClasses(t, b); len(classes) != 0 {
+		t.Errorf("got %+v, want no classes", classes)
+	}
+	if bookings, _ := b.GetBookings(); len(bookings) != 0 {
+		t.Errorf("got %+v, want no bookings", bookings)
+	}
+	if rooms, _ := b.GetRooms(); len(rooms) != 0 {
+		t.Errorf("got %+v, want no rooms", rooms)
+	}
+
+	// nor change it
+	assertNotFound(t, b.UpdateClass(c.ID, &models.Class{Name: "Yoga"}))
+	assertNotFound(t, b.DeleteClass(c.ID))
+	assertNotFound(t, b.UpdateBooking(booking.ID, &models.Booking{Class: c.ID, Customer: "Bar"}))
+	assertNotFound(t, b.DeleteBooking(booking.ID))
+	_, err = b.CancelBooking(booking.ID, "Bar", "")
+	assertNotFound(t, err)
+	_, err = b.AddBooking(&models.Booking{Class: c.ID, Customer: "Bar", Date: date("2020-01-15")})
+	assertNotFound(t, err)
+	_, err = b.AddClass(&models.Class{Name: "Yoga", StartDate: date("2020-01-01"), EndDate: date("2020-01-31"), Room: room.ID})
+	assertNotFound(t, err)
+	assertNotFound(t, b.UpdateRoom(room.ID, &models.Room{Location: room.Location, Name: "Studio 2"}))
+	assertNotFound(t, b.DeleteInstructor(instructor.ID))
+	err = b.WithTx(context.Background(), func(tx storage.Tx) error {
+		return tx.DeleteClass(c.ID)
+	})
+	assertNotFound(t, err)
+
+	// the data of `a` is untouched
+	stored, err := a.GetBooking(booking.ID)
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if stored.Status != models.BookingConfirmed || stored.Customer != "Foo" {
+		t.Errorf("got %+v, want %+v", stored, booking)
+	}
+	if _, err := s.ForTenant("a").GetClass(c.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// views don't own the storage
+	if err := a.Close(); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if _, err := s.GetClasses(); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	tenants, err := s.Tenants()
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if len(tenants) < 2 || tenants[0] != "" || tenants[1] != "a" {
+		t.Errorf("got %q, want the default tenant and a", tenants)
+	}
+}
+
+func testAddTenant(t *testing.T, s storage.Storage) {
+	// reading through a view doesn't make the tenant known
+	unknown := s.ForTenant("unknown")
+	if classes := mustGetClasses(t, unknown); len(classes) != 0 {
+		t.Errorf("got %+v, want no classes", classes)
+	}
+	if bookings, err := unknown.GetBookings(); err != nil || len(bookings) != 0 {
+		t.Errorf("got %+v, %v, want no bookings", bookings, err)
+	}
+	_, err := unknown.GetClass("PI0001")
+	assertNotFound(t, err)
+	if tenants := mustGetTenants(t, s); contains(tenants, "unknown") {
+		t.Errorf("got %q, want no unknown tenant", tenants)
+	}
+	if known, err := s.HasTenant("unknown"); err != nil || known {
+		t.Errorf("got %v, %v, want an unknown tenant", known, err)
+	}
+	if known, err := s.HasTenant(""); err != nil || !known {
+		t.Errorf("got %v, %v, want the default tenant", known, err)
+	}
+
+	// provisioned tenants are known before having any data
+	for i := 0; i < 2; i++ {
+		if err := s.AddTenant("provisioned"); err != nil {
+			t.Fatalf("got %s", err)
+		}
+	}
+	if tenants := mustGetTenants(t, s); !contains(tenants, "provisioned") {
+		t.Errorf("got %q, want the provisioned tenant", tenants)
+	}
+	if known, err := s.HasTenant("provisioned"); err != nil || !known {
+		t.Errorf("got %v, %v, want a known tenant", known, err)
+	}
+	if classes := mustGetClasses(t, s.ForTenant("provisioned")); len(classes) != 0 {
+		t.Errorf("got %+v, want no classes", classes)
+	}
+
+	// and so are the ones written to
+	addClass(t, s.ForTenant("written"), "Pilates")
+	if tenants := mustGetTenants(t, s); !contains(tenants, "written") {
+		t.Errorf("got %q, want the tenant written to", tenants)
+	}
+	if known, err := s.HasTenant("written"); err != nil || !known {
+		t.Errorf("got %v, %v, want a known tenant", known, err)
+	}
+}
+
+func mustGetTenants(t *testing.T, s storage.Storage) []string {
+	t.Helper()
+	tenants, err := s.Tenants()
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	return tenants
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Transactions
+*/
+
+func testWithTx(t *testing.T, s storage.Storage) {
+	c := addClass(t, s, "Pilates")
+	b := addBooking(t, s, c.ID, "Foo")
+
+	// changes are committed together
+	var moved *models.Class
+	err := s.WithTx(context.Background(), func(tx storage.Tx) error {
+		moved = &models.Class{Name: "Yoga", StartDate: c.StartDate, EndDate: c.EndDate}
+		if _, err := tx.AddClass(moved); err != nil {
+			return err
+		}
+		// the transaction sees its own changes
+		if _, err := tx.GetClass(moved.ID); err != nil {
+			return err
+		}
+		b.Class = moved.ID
+		return tx.UpdateBooking(b.ID, b)
+	})
+	if err != nil {
+		t.Fatalf("got %s", err)
+	}
+	if got, _ := s.GetBooking(b.ID); got == nil || got.Class != moved.ID {
+		t.Errorf("got %+v, want class %s", got, moved.ID)
+	}
+
+	// and discarded when the function fails
+	boom := errors.New("boom")
+	var added *models.Class
+	err = s.WithTx(context.Background(), func(tx storage.Tx) error {
+		added = &models.Class{Name: "Boxing"}
+		if _, err := tx.AddClass(added); err != nil {
+			return err
+		}
+		if err := tx.DeleteBooking(b.ID); err != nil {
+			return err
+		}
+		if err := tx.DeleteClass(moved.ID); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("got %v, want %s", err, boom)
+	}
+	_, err = s.GetClass(added.ID)
+	assertNotFound(t, err)
+	if _, err := s.GetBooking(b.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+	if _, err := s.GetClass(moved.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+
+	// or when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.WithTx(ctx, func(tx storage.Tx) error {
+		return tx.DeleteBooking(b.ID)
+	})
+	if err == nil {
+		t.Errorf("got nil, want error")
+	}
+	if _, err := s.GetBooking(b.ID); err != nil {
+		t.Errorf("got %s", err)
+	}
+}
+
+func testClose(t *testing.T, s storage.Storage) {
+	err := s.Close()
+	if err != nil {
+		t.Errorf("got %s", err)
+	}
+}
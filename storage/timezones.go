@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/masci/go-rest-playground/models"
+)
+
+/*
+	Time zones
+
+	Classes take place in the time zone of their own, or of the location of
+	their room, UTC being the default. Their start and end dates are calendar
+	days, stored as midnight UTC, which start at midnight local time.
+*/
+
+// checkTimeZone fails when `name` isn't a time zone of the IANA database,
+// an empty name stands for UTC
+func checkTimeZone(name string) error {
+	if name == "Local" {
+		// depends on the machine running the service
+		return fmt.Errorf("unknown time zone %q", name)
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("unknown time zone %q", name)
+	}
+	return nil
+}
+
+// ClassLocation returns the time zone the class takes place in
+func ClassLocation(tx Tx, c *models.Class) (*time.Location, error) {
+	name := c.TimeZone
+	if name == "" && c.Room != 0 {
+		r, err := tx.GetRoom(c.Room)
+		if err != nil {
+			return nil, err
+		}
+		l, err := tx.GetLocation(r.Location)
+		if err != nil {
+			return nil, err
+		}
+		name = l.TimeZone
+	}
+
+	return time.LoadLocation(name)
+}
+
+// localDay returns the midnight starting the calendar day `date` in the
+// time zone `loc`
+func localDay(date time.Time, loc *time.Location) time.Time {
+	y, m, d := date.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
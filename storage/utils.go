@@ -13,8 +13,25 @@ import (
 // WARNING: this doesn't work if input string length is < 2 but not sure is
 // worth it addressing the issue, using random identifiers isn't great in the
 // first place
-func makeID(name string) string {
-	return fmt.Sprintf("%s%04d", strings.ToUpper(name[:2]), rand.Intn(10000))
+func makeID(rnd *rand.Rand, name string) string {
+	return fmt.Sprintf("%s%04d", strings.ToUpper(name[:2]), rnd.Intn(10000))
+}
+
+// newClassID asks the generator for identifiers until it finds one that's
+// not in use yet, according to the `exists` function
+func newClassID(ids IDGenerator, name string, exists func(ID string) (bool, error)) (string, error) {
+	for i := 0; i < 100; i++ {
+		ID := ids.NewID(name)
+		found, err := exists(ID)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find a free identifier for class %s", name)
 }
 
 // createTime is a tiny helper to create dates with a consistent format
@@ -24,8 +41,30 @@ func createTime(date string) time.Time {
 	return t
 }
 
-// canBook returns wether the class can be booked or not
-// according to its date availability
-func canBook(b *models.Booking, c *models.Class) bool {
-	return b.Date.After(c.StartDate) && b.Date.Before(c.EndDate)
+// canBook returns wether the class can be booked or not according to its
+// date availability: the booking must fall on one of the days from the start
+// date to the end date of the class, both included, in its time zone `loc`
+func canBook(b *models.Booking, c *models.Class, loc *time.Location) bool {
+	return withinDays(b.Date, c.StartDate, c.EndDate, loc)
+}
+
+// bookingWindow returns when booking the session of the class taking place
+// at `t` opens and closes, zero times meaning no limit. Days are counted in
+// the time zone `loc` of the class, so that they last 23 or 25 hours when
+// the clocks change.
+func bookingWindow(c *models.Class, t time.Time, loc *time.Location) (opens, closes time.Time) {
+	if c.OpensDays > 0 {
+		opens = t.In(loc).AddDate(0, 0, -c.OpensDays)
+	}
+	if c.ClosesMinutes > 0 {
+		closes = t.Add(-time.Duration(c.ClosesMinutes) * time.Minute)
+	}
+	return opens, closes
+}
+
+// withinDays tells wether `t` falls on one of the days from `start` to `end`,
+// both included, in the time zone `loc`
+func withinDays(t, start, end time.Time, loc *time.Location) bool {
+	opens, closes := localDay(start, loc), localDay(end, loc).AddDate(0, 0, 1)
+	return !t.Before(opens) && t.Before(closes)
 }
@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/masci/go-rest-playground/models"
 )
 
 func TestMakeID(t *testing.T) {
-	rand.Seed(42) // make tests reproducible by having the same rand sequence every time
+	rnd := rand.New(rand.NewSource(42)) // make tests reproducible by having the same rand sequence every time
 
 	var tests = []struct {
 		input string
@@ -23,7 +24,7 @@ func TestMakeID(t *testing.T) {
 	for _, tt := range tests {
 		testname := fmt.Sprintf("%s,%s", tt.input, tt.want)
 		t.Run(testname, func(t *testing.T) {
-			id := makeID(tt.input)
+			id := makeID(rnd, tt.input)
 			if id != tt.want {
 				t.Errorf("got %s, want %s", id, tt.want)
 			}
@@ -67,6 +68,47 @@ func TestCanBook(t *testing.T) {
 			},
 			true,
 		},
+		// both the first and the last day are included, whole
+		{
+			&models.Booking{
+				Date: createTime("2020-01-01"),
+			},
+			&models.Class{
+				StartDate: createTime("2020-01-01"),
+				EndDate:   createTime("2020-01-31"),
+			},
+			true,
+		},
+		{
+			&models.Booking{
+				Date: createTime("2020-01-31").Add(23 * time.Hour),
+			},
+			&models.Class{
+				StartDate: createTime("2020-01-01"),
+				EndDate:   createTime("2020-01-31"),
+			},
+			true,
+		},
+		{
+			&models.Booking{
+				Date: createTime("2020-02-01"),
+			},
+			&models.Class{
+				StartDate: createTime("2020-01-01"),
+				EndDate:   createTime("2020-01-31"),
+			},
+			false,
+		},
+		{
+			&models.Booking{
+				Date: createTime("2019-12-31").Add(23 * time.Hour),
+			},
+			&models.Class{
+				StartDate: createTime("2020-01-01"),
+				EndDate:   createTime("2020-01-31"),
+			},
+			false,
+		},
 		{
 			&models.Booking{
 				Date: createTime("2019-01-29"),
@@ -81,10 +123,66 @@ func TestCanBook(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run("", func(t *testing.T) {
-			can := canBook(tt.b, tt.c)
+			can := canBook(tt.b, tt.c, time.UTC)
 			if can != tt.want {
 				t.Errorf("got %t, want %t", can, tt.want)
 			}
 		})
 	}
 }
+
+func TestCanBookTimeZone(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// from the start of summer time to the end of it, in 2022
+	c := &models.Class{StartDate: createTime("2022-03-27"), EndDate: createTime("2022-10-30")}
+
+	var tests = []struct {
+		date time.Time
+		want bool
+	}{
+		// midnight in Rome is still the day before in UTC
+		{time.Date(2022, 3, 27, 0, 30, 0, 0, rome), true},
+		{time.Date(2022, 3, 26, 23, 30, 0, 0, rome), false},
+		// a 7am class is at 6am UTC in winter and at 5am UTC in summer
+		{time.Date(2022, 3, 27, 7, 0, 0, 0, rome), true},
+		{time.Date(2022, 3, 27, 5, 0, 0, 0, time.UTC), true},
+		// the last day is 25 hours long, as summer time ends
+		{time.Date(2022, 10, 30, 0, 30, 0, 0, rome), true},
+		{time.Date(2022, 10, 30, 22, 30, 0, 0, time.UTC), true},
+		{time.Date(2022, 10, 30, 23, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date.String(), func(t *testing.T) {
+			can := canBook(&models.Booking{Date: tt.date}, c, rome)
+			if can != tt.want {
+				t.Errorf("got %t, want %t", can, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameDay(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 7am the day after summer time starts, and 11:30pm the day it starts
+	a := time.Date(2022, 3, 28, 5, 0, 0, 0, time.UTC)
+	b := time.Date(2022, 3, 27, 21, 30, 0, 0, time.UTC)
+	if sameDay(a, b, rome) {
+		t.Errorf("%s and %s are on the same day in %s", a, b, rome)
+	}
+	// 12:30am and 7am, both on the day after
+	b = time.Date(2022, 3, 27, 22, 30, 0, 0, time.UTC)
+	if !sameDay(a, b, rome) {
+		t.Errorf("%s and %s aren't on the same day in %s", a, b, rome)
+	}
+	if sameDay(a, b, time.UTC) {
+		t.Errorf("%s and %s are on the same day in UTC", a, b)
+	}
+}
@@ -1,30 +1,231 @@
 package storage
 
 import (
-	"fmt"
+	"context"
+	"sort"
+	"sync"
 
 	"github.com/masci/go-rest-playground/models"
 )
 
 // VolatileStorage implements a trivial in-memory storage for the
-// Storage interface using maps.
+// Storage interface using maps. Objects are copied in and out of the
+// maps, so that callers can't change the data behind the storage's back.
+//
+// Each tenant has its own maps, the views returned by ForTenant share them
+// along with the lock.
 type VolatileStorage struct {
+	*options
+	*volatileTenants
+	tenant    string
+	snapshots *snapshotter
+}
+
+// volatileTenants holds the data of every tenant, keyed by tenant ID
+type volatileTenants struct {
+	mu      sync.RWMutex
+	tenants map[string]*volatileData
+}
+
+// volatileData holds the maps of a tenant, its methods implement the Tx
+// interface and expect the lock to be held by the caller.
+//
+// Stored objects are never changed in place but replaced, so that copies
+// of the maps can safely share them.
+type volatileData struct {
+	*options
 	classes         map[string]*models.Class
+	class_ids       []string // keeps track of the insertion order
 	bookings        map[int]*models.Booking
 	last_booking_id int
+	bans            map[int]*models.Ban
+	last_ban_id     int
+
+	instructors        map[int]*models.Instructor
+	last_instructor_id int
+
+	locations        map[int]*models.Location
+	last_location_id int
+	rooms            map[int]*models.Room
+	last_room_id     int
+
+	blackouts        map[int]*models.Blackout
+	last_blackout_id int
 }
 
-// NewVolatileStorage creates the data in memory and loads the initial fixtures.
-func NewVolatileStorage() Storage {
-	c := map[string]*models.Class{}
-	for _, item := range classes {
-		c[item.ID] = item
+// NewVolatileStorage creates the data in memory and loads the seed, if any,
+// for the default tenant. It panics when the snapshot configured with
+// WithSnapshot can't be restored, see OpenVolatileStorage.
+func NewVolatileStorage(opts ...Option) Storage {
+	s, err := OpenVolatileStorage(opts...)
+	if err != nil {
+		panic(err)
 	}
+	return s
+}
+
+// OpenVolatileStorage works like NewVolatileStorage, but when a snapshot file
+// is configured with WithSnapshot the data saved there is restored first, and
+// the storage isn't created when that fails.
+func OpenVolatileStorage(opts ...Option) (Storage, error) {
+	o := newOptions(opts)
+	s := &VolatileStorage{
+		options: o,
+		volatileTenants: &volatileTenants{
+			tenants: map[string]*volatileData{"": newVolatileData(o)},
+		},
+	}
+
+	if s.snapshot != "" {
+		if err := s.restore(s.snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range s.seed.Classes {
+		if _, found := s.data().classes[item.ID]; !found {
+			s.data().putClass(item)
+		}
+	}
+
+	if s.snapshot != "" {
+		s.snapshots = startSnapshots(s, s.snapshot, s.snapshotEvery)
+	}
+
+	return s, nil
+}
 
-	return &VolatileStorage{
-		classes:  c,
+func newVolatileData(o *options) *volatileData {
+	return &volatileData{
+		options:  o,
+		classes:  map[string]*models.Class{},
 		bookings: map[int]*models.Booking{},
+		bans:     map[int]*models.Ban{},
+
+		instructors: map[int]*models.Instructor{},
+		locations:   map[int]*models.Location{},
+		rooms:       map[int]*models.Room{},
+		blackouts:   map[int]*models.Blackout{},
+	}
+}
+
+// data returns the maps of the tenant, the caller must hold the lock. A
+// tenant that was never written to gets empty maps, which aren't kept.
+func (s *VolatileStorage) data() *volatileData {
+	if d, ok := s.tenants[s.tenant]; ok {
+		return d
+	}
+	return newVolatileData(s.options)
+}
+
+// provisioned returns the maps of the tenant, creating them the first
+// time: writers use it instead of data, holding the write lock
+func (s *VolatileStorage) provisioned() *volatileData {
+	if _, ok := s.tenants[s.tenant]; !ok {
+		s.tenants[s.tenant] = newVolatileData(s.options)
+	}
+	return s.tenants[s.tenant]
+}
+
+// ForTenant returns a view sharing the maps and the lock of the storage,
+// the maps of the tenant are created by its first write
+func (s *VolatileStorage) ForTenant(ID string) Storage {
+	return &VolatileStorage{options: s.options, volatileTenants: s.volatileTenants, tenant: ID}
+}
+
+func (s *VolatileStorage) AddTenant(ID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ForTenant(ID).(*VolatileStorage).provisioned()
+	return nil
+}
+
+func (s *VolatileStorage) HasTenant(ID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.tenants[ID]
+	return ok, nil
+}
+
+func (s *VolatileStorage) Tenants() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tenants := make([]string, 0, len(s.tenants))
+	for ID := range s.tenants {
+		tenants = append(tenants, ID)
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// WithTx runs `fn` holding the lock, on a copy of the data that replaces
+// the current one only when `fn` succeeds
+func (s *VolatileStorage) WithTx(ctx context.Context, fn func(Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data := s.data().clone()
+	if err := fn(data); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.tenants[s.tenant] = data
+	return nil
+}
+
+// clone returns a copy of the maps, sharing the objects
+func (d *volatileData) clone() *volatileData {
+	c := &volatileData{
+		options:         d.options,
+		classes:         make(map[string]*models.Class, len(d.classes)),
+		class_ids:       append([]string{}, d.class_ids...),
+		bookings:        make(map[int]*models.Booking, len(d.bookings)),
+		last_booking_id: d.last_booking_id,
+		bans:            make(map[int]*models.Ban, len(d.bans)),
+		last_ban_id:     d.last_ban_id,
+
+		instructors:        make(map[int]*models.Instructor, len(d.instructors)),
+		last_instructor_id: d.last_instructor_id,
+
+		locations:        make(map[int]*models.Location, len(d.locations)),
+		last_location_id: d.last_location_id,
+		rooms:            make(map[int]*models.Room, len(d.rooms)),
+		last_room_id:     d.last_room_id,
+
+		blackouts:        make(map[int]*models.Blackout, len(d.blackouts)),
+		last_blackout_id: d.last_blackout_id,
+	}
+	for ID, class := range d.classes {
+		c.classes[ID] = class
+	}
+	for ID, booking := range d.bookings {
+		c.bookings[ID] = booking
+	}
+	for ID, ban := range d.bans {
+		c.bans[ID] = ban
+	}
+	for ID, instructor := range d.instructors {
+		c.instructors[ID] = instructor
+	}
+	for ID, location := range d.locations {
+		c.locations[ID] = location
 	}
+	for ID, room := range d.rooms {
+		c.rooms[ID] = room
+	}
+	for ID, blackout := range d.blackouts {
+		c.blackouts[ID] = blackout
+	}
+
+	return c
 }
 
 /*
@@ -32,41 +233,380 @@ func NewVolatileStorage() Storage {
 */
 
 func (s *VolatileStorage) AddClass(c *models.Class) (string, error) {
-	c.ID = makeID(c.Name)
-	s.classes[c.ID] = c
-	return c.ID, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddClass(c)
 }
 
 func (s *VolatileStorage) GetClasses() ([]*models.Class, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetClasses()
+}
+
+func (s *VolatileStorage) GetClass(ID string) (*models.Class, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetClass(ID)
+}
+
+func (s *VolatileStorage) UpdateClass(ID string, c *models.Class) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().UpdateClass(ID, c)
+}
+
+func (s *VolatileStorage) DeleteClass(ID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().DeleteClass(ID)
+}
+
+func (d *volatileData) AddClass(c *models.Class) (string, error) {
+	if err := checkClass(d, c, ""); err != nil {
+		return "", err
+	}
+	ID, err := newClassID(d.ids, c.Name, func(ID string) (bool, error) {
+		_, found := d.classes[ID]
+		return found, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.ID = ID
+	d.putClass(c)
+	return c.ID, nil
+}
+
+func (d *volatileData) GetClasses() ([]*models.Class, error) {
 	retVal := []*models.Class{}
-	for _, val := range s.classes {
-		retVal = append(retVal, val)
+	for _, ID := range d.class_ids {
+		c := *d.classes[ID]
+		retVal = append(retVal, &c)
 	}
 
 	return retVal, nil
 }
 
-func (s *VolatileStorage) GetClass(ID string) (*models.Class, error) {
-	val, ok := s.classes[ID]
+func (d *volatileData) GetClass(ID string) (*models.Class, error) {
+	val, ok := d.classes[ID]
 	if ok {
-		return val, nil
+		c := *val
+		return &c, nil
 	}
 
-	return nil, fmt.Errorf("no Class found with id '%s'", ID)
+	return nil, notFound("Class", ID)
 }
 
-func (s *VolatileStorage) UpdateClass(ID string, c *models.Class) error {
-	_, ok := s.classes[ID]
+func (d *volatileData) UpdateClass(ID string, c *models.Class) error {
+	_, ok := d.classes[ID]
 	if ok {
-		s.classes[ID] = c
+		if err := checkClass(d, c, ID); err != nil {
+			return err
+		}
+		c.ID = ID
+		stored := *c
+		d.classes[ID] = &stored
 		return nil
 	}
 
-	return fmt.Errorf("no Class found with id '%s'", ID)
+	return notFound("Class", ID)
 }
 
-func (s *VolatileStorage) DeleteClass(ID string) error {
-	delete(s.classes, ID)
+func (d *volatileData) DeleteClass(ID string) error {
+	if _, ok := d.classes[ID]; !ok {
+		return notFound("Class", ID)
+	}
+
+	delete(d.classes, ID)
+	for i, val := range d.class_ids {
+		if val == ID {
+			// don't touch the backing array, a clone might share it
+			d.class_ids = append(d.class_ids[:i:i], d.class_ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// putClass stores a copy of a new class
+func (d *volatileData) putClass(c *models.Class) {
+	stored := *c
+	d.classes[c.ID] = &stored
+	d.class_ids = append(d.class_ids, c.ID)
+}
+
+/*
+	Instructor management functions
+*/
+
+func (s *VolatileStorage) AddInstructor(i *models.Instructor) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddInstructor(i)
+}
+
+func (s *VolatileStorage) GetInstructors() ([]*models.Instructor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetInstructors()
+}
+
+func (s *VolatileStorage) GetInstructor(ID int) (*models.Instructor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetInstructor(ID)
+}
+
+func (s *VolatileStorage) UpdateInstructor(ID int, i *models.Instructor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().UpdateInstructor(ID, i)
+}
+
+func (s *VolatileStorage) DeleteInstructor(ID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().DeleteInstructor(ID)
+}
+
+func (d *volatileData) AddInstructor(i *models.Instructor) (int, error) {
+	d.last_instructor_id++
+	i.ID = d.last_instructor_id
+	stored := *i
+	d.instructors[i.ID] = &stored
+	return i.ID, nil
+}
+
+func (d *volatileData) GetInstructors() ([]*models.Instructor, error) {
+	retVal := []*models.Instructor{}
+	for _, val := range d.instructors {
+		i := *val
+		retVal = append(retVal, &i)
+	}
+	sort.Slice(retVal, func(i, j int) bool { return retVal[i].ID < retVal[j].ID })
+
+	return retVal, nil
+}
+
+func (d *volatileData) GetInstructor(ID int) (*models.Instructor, error) {
+	val, ok := d.instructors[ID]
+	if ok {
+		i := *val
+		return &i, nil
+	}
+
+	return nil, notFound("Instructor", ID)
+}
+
+func (d *volatileData) UpdateInstructor(ID int, i *models.Instructor) error {
+	_, ok := d.instructors[ID]
+	if ok {
+		i.ID = ID
+		stored := *i
+		d.instructors[ID] = &stored
+		return nil
+	}
+
+	return notFound("Instructor", ID)
+}
+
+func (d *volatileData) DeleteInstructor(ID int) error {
+	if _, ok := d.instructors[ID]; !ok {
+		return notFound("Instructor", ID)
+	}
+	if err := checkUnassigned(d, ID); err != nil {
+		return err
+	}
+
+	delete(d.instructors, ID)
+	return nil
+}
+
+/*
+	Location management functions
+*/
+
+func (s *VolatileStorage) AddLocation(l *models.Location) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddLocation(l)
+}
+
+func (s *VolatileStorage) GetLocations() ([]*models.Location, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetLocations()
+}
+
+func (s *VolatileStorage) GetLocation(ID int) (*models.Location, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetLocation(ID)
+}
+
+func (s *VolatileStorage) UpdateLocation(ID int, l *models.Location) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().UpdateLocation(ID, l)
+}
+
+func (s *VolatileStorage) DeleteLocation(ID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().DeleteLocation(ID)
+}
+
+func (d *volatileData) AddLocation(l *models.Location) (int, error) {
+	if err := checkTimeZone(l.TimeZone); err != nil {
+		return -1, err
+	}
+	d.last_location_id++
+	l.ID = d.last_location_id
+	stored := *l
+	d.locations[l.ID] = &stored
+	return l.ID, nil
+}
+
+func (d *volatileData) GetLocations() ([]*models.Location, error) {
+	retVal := []*models.Location{}
+	for _, val := range d.locations {
+		l := *val
+		retVal = append(retVal, &l)
+	}
+	sort.Slice(retVal, func(i, j int) bool { return retVal[i].ID < retVal[j].ID })
+
+	return retVal, nil
+}
+
+func (d *volatileData) GetLocation(ID int) (*models.Location, error) {
+	val, ok := d.locations[ID]
+	if ok {
+		l := *val
+		return &l, nil
+	}
+
+	return nil, notFound("Location", ID)
+}
+
+func (d *volatileData) UpdateLocation(ID int, l *models.Location) error {
+	_, ok := d.locations[ID]
+	if ok {
+		if err := checkTimeZone(l.TimeZone); err != nil {
+			return err
+		}
+		l.ID = ID
+		stored := *l
+		d.locations[ID] = &stored
+		return nil
+	}
+
+	return notFound("Location", ID)
+}
+
+func (d *volatileData) DeleteLocation(ID int) error {
+	if _, ok := d.locations[ID]; !ok {
+		return notFound("Location", ID)
+	}
+	if err := checkNoRooms(d, ID); err != nil {
+		return err
+	}
+
+	delete(d.locations, ID)
+	return nil
+}
+
+/*
+	Room management functions
+*/
+
+func (s *VolatileStorage) AddRoom(r *models.Room) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddRoom(r)
+}
+
+func (s *VolatileStorage) GetRooms() ([]*models.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetRooms()
+}
+
+func (s *VolatileStorage) GetRoom(ID int) (*models.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetRoom(ID)
+}
+
+func (s *VolatileStorage) UpdateRoom(ID int, r *models.Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().UpdateRoom(ID, r)
+}
+
+func (s *VolatileStorage) DeleteRoom(ID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().DeleteRoom(ID)
+}
+
+func (d *volatileData) AddRoom(r *models.Room) (int, error) {
+	if err := checkLocation(d, r); err != nil {
+		return -1, err
+	}
+	d.last_room_id++
+	r.ID = d.last_room_id
+	stored := *r
+	d.rooms[r.ID] = &stored
+	return r.ID, nil
+}
+
+func (d *volatileData) GetRooms() ([]*models.Room, error) {
+	retVal := []*models.Room{}
+	for _, val := range d.rooms {
+		r := *val
+		retVal = append(retVal, &r)
+	}
+	sort.Slice(retVal, func(i, j int) bool { return retVal[i].ID < retVal[j].ID })
+
+	return retVal, nil
+}
+
+func (d *volatileData) GetRoom(ID int) (*models.Room, error) {
+	val, ok := d.rooms[ID]
+	if ok {
+		r := *val
+		return &r, nil
+	}
+
+	return nil, notFound("Room", ID)
+}
+
+func (d *volatileData) UpdateRoom(ID int, r *models.Room) error {
+	_, ok := d.rooms[ID]
+	if ok {
+		if err := checkLocation(d, r); err != nil {
+			return err
+		}
+		r.ID = ID
+		stored := *r
+		d.rooms[ID] = &stored
+		return nil
+	}
+
+	return notFound("Room", ID)
+}
+
+func (d *volatileData) DeleteRoom(ID int) error {
+	if _, ok := d.rooms[ID]; !ok {
+		return notFound("Room", ID)
+	}
+	if err := checkRoomUnused(d, ID); err != nil {
+		return err
+	}
+
+	delete(d.rooms, ID)
 	return nil
 }
 
@@ -75,61 +615,296 @@ func (s *VolatileStorage) DeleteClass(ID string) error {
 */
 
 func (s *VolatileStorage) AddBooking(b *models.Booking) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddBooking(b)
+}
+
+func (s *VolatileStorage) GetBookings() ([]*models.Booking, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetBookings()
+}
+
+func (s *VolatileStorage) GetBooking(ID int) (*models.Booking, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetBooking(ID)
+}
+
+func (s *VolatileStorage) UpdateBooking(ID int, booking *models.Booking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().UpdateBooking(ID, booking)
+}
+
+func (s *VolatileStorage) DeleteBooking(ID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().DeleteBooking(ID)
+}
+
+func (s *VolatileStorage) CancelBooking(ID int, by, reason string) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().CancelBooking(ID, by, reason)
+}
+
+func (s *VolatileStorage) CheckIn(ID int) (*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().CheckIn(ID)
+}
+
+func (s *VolatileStorage) MarkNoShows() ([]*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().MarkNoShows()
+}
+
+func (d *volatileData) AddBooking(b *models.Booking) (int, error) {
 	// check wether the booking is valid
-	class, err := s.GetClass(b.Class)
+	class, ok := d.classes[b.Class]
+	if !ok {
+		return -1, notFound("Class", b.Class)
+	}
+	class, err := roomCapacity(d, class)
 	if err != nil {
 		return -1, err
 	}
-	if !canBook(b, class) {
-		return -1, fmt.Errorf("class %s is not available at %s", class.Name, b.Date)
+	if err := checkBanned(d, d.clock, b.Customer); err != nil {
+		return -1, err
+	}
+	taken := []*models.Booking{}
+	for _, other := range d.bookings {
+		taken = append(taken, other)
+	}
+	if err := checkBooking(d, d.clock, b, class, taken); err != nil {
+		return -1, err
 	}
 
 	// proceed with booking creation
-	s.last_booking_id++
-	b.ID = s.last_booking_id
-	s.bookings[b.ID] = b
+	d.last_booking_id++
+	b.ID = d.last_booking_id
+	stored := *b
+	d.bookings[b.ID] = &stored
 	return b.ID, nil
 }
 
-func (s *VolatileStorage) GetBookings() ([]*models.Booking, error) {
+func (d *volatileData) GetBookings() ([]*models.Booking, error) {
 	retVal := []*models.Booking{}
-	for _, val := range s.bookings {
-		retVal = append(retVal, val)
+	for _, val := range d.bookings {
+		b := *val
+		retVal = append(retVal, &b)
 	}
+	sort.Slice(retVal, func(i, j int) bool { return retVal[i].ID < retVal[j].ID })
 
 	return retVal, nil
 }
 
-func (s *VolatileStorage) GetBooking(ID int) (*models.Booking, error) {
-	val, ok := s.bookings[ID]
+func (d *volatileData) GetBooking(ID int) (*models.Booking, error) {
+	val, ok := d.bookings[ID]
 	if ok {
-		return val, nil
+		b := *val
+		return &b, nil
 	}
 
-	return nil, fmt.Errorf("no Booking found with id '%d'", ID)
+	return nil, notFound("Booking", ID)
+}
+
+func (d *volatileData) UpdateBooking(ID int, booking *models.Booking) error {
+	old, ok := d.bookings[ID]
+	if !ok {
+		return notFound("Booking", ID)
+	}
+	if err := checkChange(d, d.clock, old, booking); err != nil {
+		return err
+	}
 
+	booking.ID = ID
+	stored := *booking
+	d.bookings[ID] = &stored
+	return nil
 }
 
-func (s *VolatileStorage) UpdateBooking(ID int, booking *models.Booking) error {
-	_, ok := s.bookings[ID]
+func (d *volatileData) DeleteBooking(ID int) error {
+	if _, ok := d.bookings[ID]; !ok {
+		return notFound("Booking", ID)
+	}
+
+	delete(d.bookings, ID)
+	return nil
+}
+
+func (d *volatileData) CancelBooking(ID int, by, reason string) (*models.Booking, error) {
+	return cancelBooking(d, d.clock, ID, by, reason)
+}
+
+func (d *volatileData) CheckIn(ID int) (*models.Booking, error) {
+	return checkIn(d, d.options, ID)
+}
+
+func (d *volatileData) MarkNoShows() ([]*models.Booking, error) {
+	return markNoShows(d, d.options)
+}
+
+/*
+	Ban management functions
+*/
+
+func (s *VolatileStorage) AddBan(ban *models.Ban) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddBan(ban)
+}
+
+func (s *VolatileStorage) GetBans() ([]*models.Ban, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetBans()
+}
+
+func (s *VolatileStorage) GetBan(ID int) (*models.Ban, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetBan(ID)
+}
+
+func (s *VolatileStorage) UpdateBan(ID int, ban *models.Ban) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().UpdateBan(ID, ban)
+}
+
+func (s *VolatileStorage) LiftBan(ID int, by string) (*models.Ban, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().LiftBan(ID, by)
+}
+
+func (d *volatileData) AddBan(ban *models.Ban) (int, error) {
+	d.last_ban_id++
+	ban.ID = d.last_ban_id
+	stored := *ban
+	d.bans[ban.ID] = &stored
+	return ban.ID, nil
+}
+
+func (d *volatileData) GetBans() ([]*models.Ban, error) {
+	retVal := []*models.Ban{}
+	for _, val := range d.bans {
+		b := *val
+		retVal = append(retVal, &b)
+	}
+	sort.Slice(retVal, func(i, j int) bool { return retVal[i].ID < retVal[j].ID })
+
+	return retVal, nil
+}
+
+func (d *volatileData) GetBan(ID int) (*models.Ban, error) {
+	val, ok := d.bans[ID]
 	if ok {
-		s.bookings[ID] = booking
+		b := *val
+		return &b, nil
+	}
+
+	return nil, notFound("Ban", ID)
+}
+
+func (d *volatileData) UpdateBan(ID int, ban *models.Ban) error {
+	_, ok := d.bans[ID]
+	if ok {
+		ban.ID = ID
+		stored := *ban
+		d.bans[ID] = &stored
 		return nil
 	}
 
-	return fmt.Errorf("no Booking found with id '%d'", ID)
+	return notFound("Ban", ID)
 }
 
-func (s *VolatileStorage) DeleteBooking(ID int) error {
-	delete(s.bookings, ID)
-	return nil
+func (d *volatileData) LiftBan(ID int, by string) (*models.Ban, error) {
+	return liftBan(d, d.clock, ID, by)
+}
+
+/*
+	Blackout management functions
+*/
+
+func (s *VolatileStorage) AddBlackout(b *models.Blackout) ([]*models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().AddBlackout(b)
+}
+
+func (s *VolatileStorage) GetBlackouts() ([]*models.Blackout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetBlackouts()
+}
+
+func (s *VolatileStorage) GetBlackout(ID int) (*models.Blackout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data().GetBlackout(ID)
+}
+
+func (s *VolatileStorage) DeleteBlackout(ID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provisioned().DeleteBlackout(ID)
+}
+
+func (d *volatileData) AddBlackout(b *models.Blackout) ([]*models.Booking, error) {
+	if err := checkBlackout(d, b); err != nil {
+		return nil, err
+	}
+	d.last_blackout_id++
+	b.ID = d.last_blackout_id
+	stored := *b
+	d.blackouts[b.ID] = &stored
+	return cancelBlackedOut(d, d.clock, b)
+}
+
+func (d *volatileData) GetBlackouts() ([]*models.Blackout, error) {
+	retVal := []*models.Blackout{}
+	for _, val := range d.blackouts {
+		b := *val
+		retVal = append(retVal, &b)
+	}
+	sort.Slice(retVal, func(i, j int) bool { return retVal[i].ID < retVal[j].ID })
+
+	return retVal, nil
+}
+
+func (d *volatileData) GetBlackout(ID int) (*models.Blackout, error) {
+	val, ok := d.blackouts[ID]
+	if ok {
+		b := *val
+		return &b, nil
+	}
+
+	return nil, notFound("Blackout", ID)
+}
+
+func (d *volatileData) DeleteBlackout(ID int) error {
+	if _, ok := d.blackouts[ID]; ok {
+		delete(d.blackouts, ID)
+		return nil
+	}
+
+	return notFound("Blackout", ID)
 }
 
 /*
 	Others
 */
 
+// Close saves a last snapshot when snapshots are enabled, otherwise,
+// as for the views, it's a noop
 func (s *VolatileStorage) Close() error {
-	/* noop */
+	if s.snapshots != nil {
+		return s.snapshots.stop()
+	}
 	return nil
 }